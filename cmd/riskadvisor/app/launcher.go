@@ -0,0 +1,230 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/pkg/kubeClient"
+)
+
+// Starts simulators and deletes them when they are not needed anymore
+type simulatorLauncher interface {
+	// Starts the simulator with given arguments and waits until it is ready.
+	// Returns the address (host:port) on which the simulator listens for requests.
+	start(name string, simulatorArgs []string) (string, error)
+	delete(name string) error
+}
+
+// Runs simulators as pods in the cluster
+type podLauncher struct {
+	clusterCommunicator kubeClient.PodOperationHandler
+	podTemplate         *v1.Pod
+	simulatorPort       string
+	namespace           string
+	startupTimeout      int
+}
+
+func newPodLauncher(clusterCommunicator kubeClient.PodOperationHandler, podTemplate *v1.Pod, simulatorPort,
+	namespace string, startupTimeout int) *podLauncher {
+	return &podLauncher{
+		clusterCommunicator: clusterCommunicator,
+		podTemplate:         podTemplate,
+		simulatorPort:       simulatorPort,
+		namespace:           namespace,
+		startupTimeout:      startupTimeout,
+	}
+}
+
+func (l *podLauncher) start(name string, simulatorArgs []string) (string, error) {
+	log.Printf("Creating simulator pod %s", name)
+	podIP, err := l.clusterCommunicator.CreatePod(newSimulatorPod(l.podTemplate, name, simulatorArgs), name, l.namespace, l.startupTimeout)
+	if err != nil {
+		log.WithError(err).Error("error creating simulator pod")
+		return "", err
+	}
+
+	address := net.JoinHostPort(podIP, l.simulatorPort)
+
+	log.Printf("Waiting until simulator %s is ready", name)
+	err = l.clusterCommunicator.WaitUntilPodReady(simulatorAliveUrl(address), l.startupTimeout)
+	if err != nil {
+		log.WithError(err).Error("error waiting for simulator pod")
+		return "", err
+	}
+
+	return address, nil
+}
+
+func (l *podLauncher) delete(name string) error {
+	return l.clusterCommunicator.DeletePod(l.namespace, name)
+}
+
+// Configuration of simulators run as processes on the risk-advisor's host, e.g. when risk-advisor
+// runs outside of the cluster. Simulators fetch the cluster state with the given kubeconfig and context.
+type LocalSimulators struct {
+	SimulatorBinary string
+	SchedulerBinary string
+	// Scheduler policy file, the default policy is used if empty
+	SchedulerPolicyFile string
+	Kubeconfig          string
+	Context             string
+}
+
+// Runs simulators as child processes listening on free local ports, each with its own scheduler process
+type processLauncher struct {
+	config         LocalSimulators
+	startupTimeout int
+	httpClient     http.Client
+
+	mutex     sync.Mutex
+	processes map[string]*simulatorProcess
+}
+
+type simulatorProcess struct {
+	cmd *exec.Cmd
+	// Closed when the process exits
+	exited chan struct{}
+}
+
+func newProcessLauncher(config LocalSimulators, startupTimeout int) *processLauncher {
+	return &processLauncher{
+		config:         config,
+		startupTimeout: startupTimeout,
+		httpClient:     http.Client{Timeout: time.Second},
+		processes:      make(map[string]*simulatorProcess),
+	}
+}
+
+func (l *processLauncher) start(name string, simulatorArgs []string) (string, error) {
+	raPort, err := freeLocalPort()
+	if err != nil {
+		return "", err
+	}
+	schedulerPort, err := freeLocalPort()
+	if err != nil {
+		return "", err
+	}
+	// Schedulers of all simulators would try to serve their health checks on the same default port
+	schedulerHealthzPort, err := freeLocalPort()
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"--address=127.0.0.1",
+		fmt.Sprintf("--ra-port=%s", raPort),
+		fmt.Sprintf("--scheduler-port=%s", schedulerPort),
+		fmt.Sprintf("--scheduler-binary=%s", l.config.SchedulerBinary),
+		fmt.Sprintf("--scheduler-args=--address=127.0.0.1 --port=%s", schedulerHealthzPort),
+	}
+	if l.config.SchedulerPolicyFile != "" {
+		args = append(args, fmt.Sprintf("--scheduler-policy-file=%s", l.config.SchedulerPolicyFile))
+	}
+	if l.config.Kubeconfig != "" {
+		args = append(args, fmt.Sprintf("--kubeconfig=%s", l.config.Kubeconfig))
+	}
+	if l.config.Context != "" {
+		args = append(args, fmt.Sprintf("--context=%s", l.config.Context))
+	}
+
+	cmd := exec.Command(l.config.SimulatorBinary, append(args, simulatorArgs...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Printf("Starting simulator process %s", name)
+	if err := cmd.Start(); err != nil {
+		log.WithError(err).Error("error starting simulator process")
+		return "", err
+	}
+
+	process := &simulatorProcess{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(process.exited)
+	}()
+
+	l.mutex.Lock()
+	l.processes[name] = process
+	l.mutex.Unlock()
+
+	address := net.JoinHostPort("127.0.0.1", raPort)
+
+	log.Printf("Waiting until simulator %s is ready", name)
+	if err := l.waitUntilReady(address, process); err != nil {
+		log.WithError(err).Error("error waiting for simulator process")
+		return "", err
+	}
+
+	return address, nil
+}
+
+// Simulator stops its scheduler process when it is terminated
+func (l *processLauncher) delete(name string) error {
+	l.mutex.Lock()
+	process, ok := l.processes[name]
+	delete(l.processes, name)
+	l.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-process.exited:
+		return nil
+	default:
+	}
+
+	if err := process.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+	<-process.exited
+
+	return nil
+}
+
+// Fails as soon as the simulator exits, e.g. because its scheduler could not start
+func (l *processLauncher) waitUntilReady(address string, process *simulatorProcess) error {
+	deadline := time.After(time.Duration(l.startupTimeout) * time.Second)
+
+	for {
+		resp, err := l.httpClient.Get(simulatorAliveUrl(address))
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+
+		select {
+		case <-process.exited:
+			return errors.New("simulator process exited before it started running")
+		case <-deadline:
+			return errors.New("timed out when waiting for simulator process to start running")
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func freeLocalPort() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("error finding a free port: %s", err)
+	}
+	defer listener.Close()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	return port, err
+}
+
+func simulatorAliveUrl(address string) string {
+	return fmt.Sprintf("http://%s/alive", address)
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessLauncherFailsWhenSimulatorExits(t *testing.T) {
+	launcher := newProcessLauncher(LocalSimulators{SimulatorBinary: "false"}, 30)
+
+	started := time.Now()
+	_, err := launcher.start("simulator", nil)
+
+	assert.EqualError(t, err, "simulator process exited before it started running")
+	assert.True(t, time.Since(started) < 10*time.Second)
+	assert.NoError(t, launcher.delete("simulator"))
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	utilrand "k8s.io/kubernetes/pkg/util/rand"
+)
+
+const simulatorNameSuffixLength = 10
+
+// Simulator leased by a single advice request
+type simulatorInstance struct {
+	name string
+	// Address (host:port) on which the simulator listens for requests
+	address string
+}
+
+// Pool of uniquely named simulators, run as pods or local processes. At most `size` simulators exist at the same time, each of them
+// serves one request and is deleted afterwards. If the pool is pre-warmed, a new simulator is started
+// in the background whenever one is released, so requests do not have to wait for the pod startup.
+// Long-lived simulators are not deleted after the request, they are reused by the next ones instead.
+type simulatorPool struct {
+	launcher       simulatorLauncher
+	startupTimeout int
+	prewarm        bool
+	reuse          bool
+	simulatorArgs  []string
+
+	// Simulators that are running and waiting for a request
+	ready chan *simulatorInstance
+	// One token for each simulator that can still be started without exceeding pool size
+	free chan struct{}
+}
+
+func newSimulatorPool(launcher simulatorLauncher, size, startupTimeout int, reuse bool, namespaces string) *simulatorPool {
+	var simulatorArgs []string
+	if reuse {
+		simulatorArgs = append(simulatorArgs, "--long-lived")
+	}
+	if namespaces != "" {
+		simulatorArgs = append(simulatorArgs, fmt.Sprintf("--namespaces=%s", namespaces))
+	}
+
+	pool := &simulatorPool{
+		launcher:       launcher,
+		startupTimeout: startupTimeout,
+		reuse:          reuse,
+		simulatorArgs:  simulatorArgs,
+		ready:          make(chan *simulatorInstance, size),
+		free:           make(chan struct{}, size),
+	}
+
+	for i := 0; i < size; i++ {
+		pool.free <- struct{}{}
+	}
+
+	return pool
+}
+
+// Starts all simulators that can be started in the background and keeps the pool full from now on.
+func (p *simulatorPool) warmUp() {
+	p.prewarm = true
+
+	for {
+		select {
+		case <-p.free:
+			go p.startInBackground()
+		default:
+			return
+		}
+	}
+}
+
+func (p *simulatorPool) lease() (*simulatorInstance, error) {
+	select {
+	case simulator := <-p.ready:
+		return simulator, nil
+	default:
+	}
+
+	select {
+	case simulator := <-p.ready:
+		return simulator, nil
+	case <-p.free:
+		simulator, err := p.start()
+		if err != nil {
+			p.free <- struct{}{}
+			return nil, err
+		}
+
+		return simulator, nil
+	case <-time.After(time.Duration(p.startupTimeout) * time.Second):
+		return nil, errors.New("timed out when waiting for a free simulator")
+	}
+}
+
+// Returns the simulator after it has served a request.
+func (p *simulatorPool) release(simulator *simulatorInstance) {
+	if p.reuse {
+		p.ready <- simulator
+		return
+	}
+
+	p.discard(simulator)
+}
+
+// Deletes the simulator, e.g. because it may be broken, and makes room for a new one.
+func (p *simulatorPool) discard(simulator *simulatorInstance) {
+	p.delete(simulator)
+
+	if p.prewarm {
+		go p.startInBackground()
+	} else {
+		p.free <- struct{}{}
+	}
+}
+
+// Deletes all simulators that are waiting for requests.
+func (p *simulatorPool) close() {
+	for {
+		select {
+		case simulator := <-p.ready:
+			p.delete(simulator)
+		default:
+			return
+		}
+	}
+}
+
+func (p *simulatorPool) startInBackground() {
+	simulator, err := p.start()
+	if err != nil {
+		// Give the token back, so the simulator will be started on demand by the next request
+		p.free <- struct{}{}
+		return
+	}
+
+	p.ready <- simulator
+}
+
+func (p *simulatorPool) start() (*simulatorInstance, error) {
+	name := fmt.Sprintf("simulator-%s", utilrand.String(simulatorNameSuffixLength))
+
+	address, err := p.launcher.start(name, p.simulatorArgs)
+	if err != nil {
+		p.delete(&simulatorInstance{name: name})
+		return nil, err
+	}
+
+	return &simulatorInstance{
+		name:    name,
+		address: address,
+	}, nil
+}
+
+func (p *simulatorPool) delete(simulator *simulatorInstance) {
+	log.Printf("Deleting simulator %s", simulator.name)
+
+	err := p.launcher.delete(simulator.name)
+	if err != nil {
+		log.WithError(err).Error("error deleting simulator")
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"testing"
+
+	mocks "github.com/Prytu/risk-advisor/cmd/riskadvisor/app/mock"
+	"github.com/Prytu/risk-advisor/pkg/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Launcher of simulator pods, which are successfully created and deleted by the returned mock
+func newTestPodLauncher() (*podLauncher, *mocks.KubernetesClientMock) {
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+
+	return newPodLauncher(clusterCommunicatorMock, DefaultSimulatorPodTemplate(), defaults.SimulatorPort,
+		defaults.SimulatorNamespace, 1), clusterCommunicatorMock
+}
+
+func TestPoolLeasesUniquelyNamedSimulators(t *testing.T) {
+	launcher, clusterCommunicatorMock := newTestPodLauncher()
+	pool := newSimulatorPool(launcher, 2, 1, false, "")
+
+	first, err := pool.lease()
+	assert.NoError(t, err)
+	second, err := pool.lease()
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first.name, second.name)
+
+	pool.release(first)
+	pool.release(second)
+
+	clusterCommunicatorMock.AssertCalled(t, "DeletePod", first.name)
+	clusterCommunicatorMock.AssertCalled(t, "DeletePod", second.name)
+}
+
+func TestPoolLimitsNumberOfSimulators(t *testing.T) {
+	launcher, _ := newTestPodLauncher()
+	pool := newSimulatorPool(launcher, 1, 1, false, "")
+
+	simulator, err := pool.lease()
+	assert.NoError(t, err)
+
+	_, err = pool.lease()
+	assert.Error(t, err)
+
+	pool.release(simulator)
+
+	_, err = pool.lease()
+	assert.NoError(t, err)
+}
+
+func TestPoolReusesLongLivedSimulators(t *testing.T) {
+	launcher, clusterCommunicatorMock := newTestPodLauncher()
+	pool := newSimulatorPool(launcher, 1, 1, true, "")
+
+	first, err := pool.lease()
+	assert.NoError(t, err)
+	pool.release(first)
+
+	second, err := pool.lease()
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	clusterCommunicatorMock.AssertNumberOfCalls(t, "CreatePod", 1)
+	clusterCommunicatorMock.AssertNotCalled(t, "DeletePod", first.name)
+}
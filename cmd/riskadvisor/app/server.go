@@ -7,34 +7,53 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"sync"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Prytu/risk-advisor/pkg/kubeClient"
 	"github.com/Prytu/risk-advisor/pkg/model"
+	"github.com/Prytu/risk-advisor/pkg/workloads"
 
 	log "github.com/Sirupsen/logrus"
 	"gopkg.in/gorilla/mux.v1"
 	"k8s.io/client-go/1.5/pkg/api/v1"
+	utilrand "k8s.io/kubernetes/pkg/util/rand"
 )
 
+// Length of the random suffix of names of stored simulation bundles
+const bundleSuffixLength = 5
+
 type AdviceService struct {
-	server                  *mux.Router
-	simulatorPort           string
-	clusterCommunicator     kubeClient.PodOperationHandler
-	httpClient              http.Client
-	simulatorStartupTimeout int
-	handlerLock             sync.Mutex
+	server     *mux.Router
+	httpClient http.Client
+	simulators *simulatorPool
+	// Directory in which simulation bundles of all requests are stored, disabled if empty
+	snapshotDir string
 }
 
+// Simulators are created as pods in simulatorNamespace from simulatorPodTemplate (the default template
+// if it is nil), unless localSimulators is given.
 func New(simulatorPort string, clusterCommunicator kubeClient.PodOperationHandler, httpClient http.Client,
-	simulatorStartupTimeout int) *AdviceService {
+	simulatorStartupTimeout int, simulatorNamespace string, simulatorPoolSize int, reuseSimulators bool,
+	namespaces string, snapshotDir string, simulatorPodTemplate *v1.Pod, localSimulators *LocalSimulators) *AdviceService {
+	if simulatorPodTemplate == nil {
+		simulatorPodTemplate = DefaultSimulatorPodTemplate()
+	}
+
+	var launcher simulatorLauncher = newPodLauncher(clusterCommunicator, simulatorPodTemplate, simulatorPort,
+		simulatorNamespace, simulatorStartupTimeout)
+	if localSimulators != nil {
+		launcher = newProcessLauncher(*localSimulators, simulatorStartupTimeout)
+	}
+
 	as := AdviceService{
-		server:                  mux.NewRouter(),
-		simulatorPort:           simulatorPort,
-		clusterCommunicator:     clusterCommunicator,
-		httpClient:              httpClient,
-		simulatorStartupTimeout: simulatorStartupTimeout,
-		handlerLock:             sync.Mutex{},
+		server:      mux.NewRouter(),
+		httpClient:  httpClient,
+		snapshotDir: snapshotDir,
+		simulators: newSimulatorPool(launcher, simulatorPoolSize, simulatorStartupTimeout, reuseSimulators,
+			namespaces),
 	}
 
 	as.register()
@@ -42,12 +61,23 @@ func New(simulatorPort string, clusterCommunicator kubeClient.PodOperationHandle
 	return &as
 }
 
+// Starts simulator pods in the background, so they are ready when advice requests come.
+func (as *AdviceService) PrewarmSimulators() {
+	as.simulators.warmUp()
+}
+
+// Deletes simulator pods that are not serving any request.
+func (as *AdviceService) Close() {
+	as.simulators.close()
+}
+
 func (as *AdviceService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	as.server.ServeHTTP(w, r)
 }
 
 func (as *AdviceService) register() {
 	as.server.HandleFunc("/advise", as.sendAdviceRequest).Methods("POST")
+	as.server.HandleFunc("/resilience", as.sendResilienceRequest).Methods("POST")
 	as.server.HandleFunc("/healthz", as.healthStatus).Methods("GET")
 }
 
@@ -56,29 +86,67 @@ func (as *AdviceService) healthStatus(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("ok"))
 }
 
+// Sends a parsed request to the simulator with the given address and name
+type simulatorCall func(simulatorAddress, simulatorName string) (interface{}, error)
+
+// Error reported by a simulator that keeps working, so the simulator is not discarded
+type simulationError struct {
+	message string
+}
+
+func (e *simulationError) Error() string {
+	return e.message
+}
+
 func (as *AdviceService) sendAdviceRequest(w http.ResponseWriter, r *http.Request) {
-	as.handlerLock.Lock()
-	defer as.handlerLock.Unlock()
+	as.forwardToSimulator(w, r, as.parseAdviceRequest)
+}
 
-	simulatorIP, err := as.startSimulatorPod()
-	defer as.cleanup()
+func (as *AdviceService) sendResilienceRequest(w http.ResponseWriter, r *http.Request) {
+	as.forwardToSimulator(w, r, as.parseResilienceRequest)
+}
+
+// Parses the request and sends it to a leased simulator, responding with its result. Invalid requests are rejected
+// before a simulator is leased. Simulators are discarded only if communication with them fails.
+func (as *AdviceService) forwardToSimulator(w http.ResponseWriter, r *http.Request,
+	parse func(request *http.Request) (simulatorCall, error)) {
+	send, err := parse(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %s", err))
+		return
+	}
+
+	simulator, err := as.simulators.lease()
 	if err != nil {
-		writeError(w, fmt.Sprintf("Error starting simulator pod: %s", err))
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting simulator pod: %s", err))
 		return
 	}
 
-	log.Print("Sending simulator request")
-	simulatorResponse, err := as.sendSimulatorRequest(simulatorIP, r)
+	// Simulator is discarded also if sending the request panics
+	broken := true
+	defer func() {
+		if broken {
+			as.simulators.discard(simulator)
+		} else {
+			as.simulators.release(simulator)
+		}
+	}()
+
+	log.Printf("Sending simulator request to %s", simulator.name)
+	simulatorResponse, err := send(simulator.address, simulator.name)
+	if _, ok := err.(*simulationError); ok || err == nil {
+		broken = false
+	}
 	if err != nil {
-		writeError(w, fmt.Sprintf("Error communicating with simulator: %s", err))
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Error communicating with simulator: %s", err))
 		return
 	}
 
-	log.Print("Received response from simulator")
+	log.Printf("Received response from simulator %s", simulator.name)
 	riskAdvisorResponse, err := json.MarshalIndent(simulatorResponse, "", " ")
 	if err != nil {
 		log.WithError(err).Error("Error writing simulator response")
-		writeError(w, fmt.Sprint("Unexpected server error."))
+		writeError(w, http.StatusInternalServerError, fmt.Sprint("Unexpected server error."))
 		return
 	}
 
@@ -86,32 +154,36 @@ func (as *AdviceService) sendAdviceRequest(w http.ResponseWriter, r *http.Reques
 	w.Write(riskAdvisorResponse)
 }
 
-func (as *AdviceService) startSimulatorPod() (string, error) {
-	log.Print("Creating simulator pod")
-	podIP, err := as.clusterCommunicator.CreatePod(simulatorPod, "simulator", "default", as.simulatorStartupTimeout)
+// Returns the table of scheduling results. Requests that ask for the capacity projection or node estimates,
+// change nodes, contain workloads or objects that were not simulated get SimulatorResponse with the results grouped
+// per workload instead.
+// Requests that ask for the snapshot get SimulationBundle, which can be replayed by an offline simulator.
+func (as *AdviceService) parseAdviceRequest(request *http.Request) (simulatorCall, error) {
+	simulatorRequest, manifests, err := as.getSimulatorRequestFromRequest(request)
 	if err != nil {
-		log.WithError(err).Error("error creating simulator pod")
-		return "", err
-	}
-
-	log.Print("Waiting until simulator is ready")
-	err = as.clusterCommunicator.WaitUntilPodReady(as.getSimulatorAliveUrl(podIP), as.simulatorStartupTimeout)
-	if err != nil {
-		log.WithError(err).Error("error waiting for simulator pod")
-		return "", err
+		return nil, err
 	}
 
-	return podIP, nil
+	return func(simulatorAddress, simulatorName string) (interface{}, error) {
+		return as.sendSimulatorRequest(simulatorAddress, simulatorName, simulatorRequest, manifests)
+	}, nil
 }
 
-func (as *AdviceService) sendSimulatorRequest(podIP string, request *http.Request) ([]model.SchedulingResult, error) {
-	simulatorRequestJSON, err := as.generateSimulatorRequest(request)
+func (as *AdviceService) sendSimulatorRequest(simulatorAddress, simulatorName string,
+	simulatorRequest *model.SimulatorRequest, manifests *workloads.Manifests) (interface{}, error) {
+	// Snapshot is fetched also when bundles are stored, but it is returned only if the user asked for it
+	returnSnapshot := simulatorRequest.Snapshot
+	simulatorRequest.Snapshot = returnSnapshot || as.snapshotDir != ""
+
+	simulatorRequestJSON, err := json.Marshal(simulatorRequest)
 	if err != nil {
-		return nil, err
+		errorMessage := "error marshalling simulatorRequest"
+		log.WithError(err).Error(errorMessage)
+		return nil, errors.New(errorMessage)
 	}
 
 	resp, err := as.httpClient.Post(
-		as.getSimulatorAdviseUrl(podIP),
+		simulatorAdviseUrl(simulatorAddress),
 		"application/json",
 		bytes.NewReader(simulatorRequestJSON),
 	)
@@ -121,6 +193,13 @@ func (as *AdviceService) sendSimulatorRequest(podIP string, request *http.Reques
 		return nil, errors.New(errorMessage)
 	}
 
+	// Simulator responds to failed simulations with the error message in SchedulingResult
+	if resp.StatusCode != http.StatusOK {
+		var simulatorError model.SchedulingResult
+		json.NewDecoder(resp.Body).Decode(&simulatorError)
+		return nil, &simulationError{fmt.Sprintf("simulation failed: %s", simulatorError.ErrorMessage)}
+	}
+
 	responseJSON, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		errorMessage := "error reading simulator request"
@@ -128,72 +207,319 @@ func (as *AdviceService) sendSimulatorRequest(podIP string, request *http.Reques
 		return nil, errors.New(errorMessage)
 	}
 
-	var simulatorResponse []model.SchedulingResult
-	err = json.Unmarshal(responseJSON, &simulatorResponse)
+	// Simulator responds with the table of results, unless the capacity projection or the snapshot was requested,
+	// or nodes were changed or estimated, or PVCs were bound
+	var simulatorResponse model.SimulatorResponse
+	if simulatorRequest.Capacity || simulatorRequest.Snapshot || !simulatorRequest.Nodes.IsEmpty() ||
+		len(simulatorRequest.Autoscale) > 0 || len(simulatorRequest.PersistentVolumeClaims) > 0 {
+		err = json.Unmarshal(responseJSON, &simulatorResponse)
+	} else {
+		err = json.Unmarshal(responseJSON, &simulatorResponse.Results)
+	}
 	if err != nil {
 		errorMessage := "error unmarshalling simulator request"
 		log.WithError(err).Error(errorMessage)
 		return nil, err
 	}
 
-	return simulatorResponse, nil
+	simulatorResponse.Workloads = workloads.Summarize(manifests.Workloads, simulatorResponse.Results)
+	simulatorResponse.Ignored = manifests.Ignored
+
+	if simulatorRequest.Snapshot {
+		bundle := newSimulationBundle(simulatorRequest, &simulatorResponse)
+		if as.snapshotDir != "" {
+			as.storeSimulationBundle(bundle, simulatorName)
+		}
+		if returnSnapshot {
+			return bundle, nil
+		}
+		simulatorResponse.Snapshot = nil
+	}
+
+	if !simulatorRequest.Capacity && simulatorRequest.Nodes.IsEmpty() && len(simulatorRequest.Autoscale) == 0 &&
+		len(simulatorResponse.PersistentVolumeClaims) == 0 && len(simulatorResponse.Workloads) == 0 &&
+		len(simulatorResponse.Ignored) == 0 {
+		return simulatorResponse.Results, nil
+	}
+
+	return &simulatorResponse, nil
 }
 
-func (as *AdviceService) cleanup() {
-	log.Print("Deleting simulator pod")
+// Failures of nodes, or groups of nodes with the same value of the domainLabel, are simulated by the simulator.
+// Request body is an optional ResilienceRequest, the domain label and the timeout in seconds can be also given
+// in the domainLabel and timeout query parameters.
+func (as *AdviceService) parseResilienceRequest(request *http.Request) (simulatorCall, error) {
+	var resilienceRequest model.ResilienceRequest
 
-	err := as.clusterCommunicator.DeletePod("default", "simulator")
+	body, err := ioutil.ReadAll(request.Body)
 	if err != nil {
-		log.WithError(err).Error("error deleting simulator pod")
+		errorMessage := "error reading request body"
+		log.WithError(err).Error(errorMessage)
+		return nil, errors.New(errorMessage)
+	}
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &resilienceRequest); err != nil {
+			errorMessage := "error unmarshalling request body"
+			log.WithError(err).Error(errorMessage)
+			return nil, errors.New(errorMessage)
+		}
+	}
+
+	if domainLabel := request.URL.Query().Get("domainLabel"); domainLabel != "" {
+		resilienceRequest.DomainLabel = domainLabel
+	}
+
+	if timeout := request.URL.Query().Get("timeout"); timeout != "" {
+		resilienceRequest.TimeoutSeconds, err = parseTimeout(timeout)
+		if err != nil {
+			errorMessage := "invalid timeout parameter"
+			log.WithError(err).Error(errorMessage)
+			return nil, errors.New(errorMessage)
+		}
+	}
+
+	if err := as.checkTimeout(resilienceRequest.TimeoutSeconds); err != nil {
+		log.WithError(err).Error("invalid timeout")
+		return nil, err
 	}
+
+	return func(simulatorAddress, _ string) (interface{}, error) {
+		return as.sendSimulatorResilienceRequest(simulatorAddress, &resilienceRequest)
+	}, nil
 }
 
-func (as *AdviceService) generateSimulatorRequest(request *http.Request) ([]byte, error) {
-	pods, err := as.getPodsFromRequest(request)
+func (as *AdviceService) sendSimulatorResilienceRequest(simulatorAddress string,
+	resilienceRequest *model.ResilienceRequest) (interface{}, error) {
+	resilienceRequestJSON, err := json.Marshal(resilienceRequest)
 	if err != nil {
-		return nil, err
+		errorMessage := "error marshalling resilienceRequest"
+		log.WithError(err).Error(errorMessage)
+		return nil, errors.New(errorMessage)
 	}
 
-	simulatorRequest := model.SimulatorRequest{ToCreate: pods}
-	simulatorRequestJSON, err := json.Marshal(simulatorRequest)
+	resp, err := as.httpClient.Post(
+		simulatorResilienceUrl(simulatorAddress),
+		"application/json",
+		bytes.NewReader(resilienceRequestJSON),
+	)
 	if err != nil {
-		errorMessage := "error marshalling simulatorRequest"
+		errorMessage := "error performing Post request to simulator"
+		log.WithError(err).Error(errorMessage)
+		return nil, errors.New(errorMessage)
+	}
+
+	// Simulator responds to failed analyses with the error message in SchedulingResult
+	if resp.StatusCode != http.StatusOK {
+		var simulatorError model.SchedulingResult
+		json.NewDecoder(resp.Body).Decode(&simulatorError)
+		return nil, &simulationError{fmt.Sprintf("resilience analysis failed: %s", simulatorError.ErrorMessage)}
+	}
+
+	var resilienceResponse model.ResilienceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&resilienceResponse); err != nil {
+		errorMessage := "error unmarshalling simulator response"
 		log.WithError(err).Error(errorMessage)
 		return nil, errors.New(errorMessage)
 	}
-	return simulatorRequestJSON, nil
+
+	return &resilienceResponse, nil
+}
+
+// Parses the timeout query parameter, which has to be a positive number of seconds.
+func parseTimeout(timeout string) (int64, error) {
+	seconds, err := strconv.ParseInt(timeout, 10, 64)
+	if err == nil && seconds <= 0 {
+		err = fmt.Errorf("timeout %d is not positive", seconds)
+	}
+
+	return seconds, err
+}
+
+// Simulation timeout has to be shorter than the timeout of requests to simulators, otherwise the request
+// fails before the simulator responds. Zero means the simulator's default timeout.
+func (as *AdviceService) checkTimeout(seconds int64) error {
+	if seconds < 0 {
+		return errors.New("timeout has to be positive")
+	}
+
+	limit := int64(as.httpClient.Timeout / time.Second)
+	if limit > 0 && seconds >= limit {
+		return fmt.Errorf("timeout has to be lower than the simulator request timeout of %d seconds", limit)
+	}
+
+	return nil
+}
+
+// Object form of the request body. Objects to create are pods or workloads, see workloads.FromJSON.
+type adviceRequest struct {
+	ToCreate       []json.RawMessage    `json:"toCreate"`
+	ToDelete       []*v1.Pod            `json:"toDelete"`
+	Nodes          *model.NodeMutations `json:"nodes"`
+	Autoscale      []model.NodeTemplate `json:"autoscale"`
+	TimeoutSeconds int64                `json:"timeoutSeconds"`
+	Capacity       bool                 `json:"capacity"`
+	Snapshot       bool                 `json:"snapshot"`
 }
 
-func (as *AdviceService) getPodsFromRequest(request *http.Request) ([]*v1.Pod, error) {
-	var pods []*v1.Pod
+// Request body is either a JSON table of objects to create, an adviceRequest object, which additionally allows
+// to specify pods that should be removed from the cluster and changes of nodes applied before scheduling,
+// or a stream of YAML documents.
+// Workloads are expanded into their pods, which are scheduled by the simulator, objects of other kinds are ignored.
+// Simulation timeout in seconds can be also given in the timeout query parameter,
+// capacity projection can be requested with capacity=true query parameter and the simulation bundle
+// with snapshot=true query parameter.
+func (as *AdviceService) getSimulatorRequestFromRequest(request *http.Request) (*model.SimulatorRequest, *workloads.Manifests, error) {
+	var userRequest adviceRequest
+	var manifests *workloads.Manifests
 
 	body, err := ioutil.ReadAll(request.Body)
 	if err != nil {
 		errorMessage := "error reading request body"
 		log.WithError(err).Error(errorMessage)
-		return nil, errors.New(errorMessage)
+		return nil, nil, errors.New(errorMessage)
 	}
 
-	err = json.Unmarshal(body, &pods)
+	if isYAML(request, body) {
+		manifests, err = workloads.FromYAML(bytes.NewReader(body))
+	} else if isJSONTable(body) {
+		err = json.Unmarshal(body, &userRequest.ToCreate)
+	} else {
+		err = json.Unmarshal(body, &userRequest)
+		if err == nil && userRequest.ToCreate == nil && userRequest.Nodes.IsEmpty() {
+			err = errors.New("missing toCreate field")
+		}
+	}
+	if _, ok := err.(*workloads.ParseError); ok {
+		errorMessage := "error parsing request body"
+		log.WithError(err).Error(errorMessage)
+		return nil, nil, fmt.Errorf("%s: %s", errorMessage, err)
+	}
 	if err != nil {
 		errorMessage := "error unmarshalling request body"
 		log.WithError(err).Error(errorMessage)
-		return nil, errors.New(errorMessage)
+		return nil, nil, errors.New(errorMessage)
+	}
+
+	if manifests == nil {
+		manifests, err = workloads.FromJSONObjects(userRequest.ToCreate)
+		if err != nil {
+			errorMessage := "error parsing request body"
+			log.WithError(err).Error(errorMessage)
+			return nil, nil, fmt.Errorf("%s: %s", errorMessage, err)
+		}
+	}
+
+	if len(manifests.Ignored) > 0 {
+		log.Printf("Ignoring objects of unsupported kinds: %s", strings.Join(manifests.Ignored, ", "))
+	}
+
+	simulatorRequest := model.SimulatorRequest{
+		ToCreate:               []*v1.Pod{},
+		ToDelete:               userRequest.ToDelete,
+		PersistentVolumeClaims: manifests.Claims(),
+		Nodes:                  userRequest.Nodes,
+		Autoscale:              userRequest.Autoscale,
+		TimeoutSeconds:         userRequest.TimeoutSeconds,
+		Capacity:               userRequest.Capacity,
+		Snapshot:               userRequest.Snapshot,
+	}
+
+	for _, workload := range manifests.Workloads {
+		simulatorRequest.ToCreate = append(simulatorRequest.ToCreate, workload.Pods...)
+	}
+
+	if timeout := request.URL.Query().Get("timeout"); timeout != "" {
+		simulatorRequest.TimeoutSeconds, err = parseTimeout(timeout)
+		if err != nil {
+			errorMessage := "invalid timeout parameter"
+			log.WithError(err).Error(errorMessage)
+			return nil, nil, errors.New(errorMessage)
+		}
+	}
+
+	if err := as.checkTimeout(simulatorRequest.TimeoutSeconds); err != nil {
+		log.WithError(err).Error("invalid timeout")
+		return nil, nil, err
+	}
+
+	if capacity := request.URL.Query().Get("capacity"); capacity != "" {
+		simulatorRequest.Capacity, err = strconv.ParseBool(capacity)
+		if err != nil {
+			errorMessage := "invalid capacity parameter"
+			log.WithError(err).Error(errorMessage)
+			return nil, nil, errors.New(errorMessage)
+		}
+	}
+
+	if snapshot := request.URL.Query().Get("snapshot"); snapshot != "" {
+		simulatorRequest.Snapshot, err = strconv.ParseBool(snapshot)
+		if err != nil {
+			errorMessage := "invalid snapshot parameter"
+			log.WithError(err).Error(errorMessage)
+			return nil, nil, errors.New(errorMessage)
+		}
+	}
+
+	return &simulatorRequest, manifests, nil
+}
+
+// Snapshot is moved from the response to the bundle, so that it is not stored twice
+func newSimulationBundle(simulatorRequest *model.SimulatorRequest, simulatorResponse *model.SimulatorResponse) *model.SimulationBundle {
+	response := *simulatorResponse
+	response.Snapshot = nil
+
+	return &model.SimulationBundle{
+		Version:  model.SimulationBundleVersion,
+		Snapshot: simulatorResponse.Snapshot,
+		Request:  simulatorRequest,
+		Response: &response,
+	}
+}
+
+// Failing to store the bundle does not fail the advice request, the error is only logged
+func (as *AdviceService) storeSimulationBundle(bundle *model.SimulationBundle, simulatorName string) {
+	bundleJSON, err := json.MarshalIndent(bundle, "", " ")
+	if err != nil {
+		log.WithError(err).Error("Error marshalling simulation bundle")
+		return
 	}
 
-	return pods, nil
+	// Reused simulators can serve many requests within a second, so file names get a random suffix
+	filename := filepath.Join(as.snapshotDir, fmt.Sprintf("%s-%s-%s.json",
+		time.Now().UTC().Format("20060102T150405Z"), simulatorName, utilrand.String(bundleSuffixLength)))
+	if err := ioutil.WriteFile(filename, bundleJSON, 0644); err != nil {
+		log.WithError(err).Error("Error storing simulation bundle")
+		return
+	}
+
+	log.Printf("Stored simulation bundle in %s", filename)
+}
+
+func simulatorAdviseUrl(simulatorAddress string) string {
+	return fmt.Sprintf("http://%s/advise", simulatorAddress)
 }
 
-func (as *AdviceService) getSimulatorAdviseUrl(podIP string) string {
-	return fmt.Sprintf("http://%s:%s/advise", podIP, as.simulatorPort)
+func simulatorResilienceUrl(simulatorAddress string) string {
+	return fmt.Sprintf("http://%s/resilience", simulatorAddress)
 }
 
-func (as *AdviceService) getSimulatorAliveUrl(podIP string) string {
-	return fmt.Sprintf("http://%s:%s/alive", podIP, as.simulatorPort)
+func isJSONTable(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+}
+
+// YAML is a superset of JSON, so JSON bodies are treated as YAML only if the content type says so
+func isYAML(request *http.Request, body []byte) bool {
+	if strings.Contains(request.Header.Get("Content-Type"), "yaml") {
+		return true
+	}
+
+	trimmedBody := bytes.TrimSpace(body)
+	return !bytes.HasPrefix(trimmedBody, []byte("[")) && !bytes.HasPrefix(trimmedBody, []byte("{"))
 }
 
-func writeError(w http.ResponseWriter, errorMsg string) {
-	writeStatusCodeAndContentType(w, http.StatusInternalServerError)
+func writeError(w http.ResponseWriter, statusCode int, errorMsg string) {
+	writeStatusCodeAndContentType(w, statusCode)
 	riskAdvisorResponse, err := json.Marshal(model.SchedulingResult{
 		ErrorMessage: errorMsg,
 	})
@@ -9,7 +9,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	mocks "github.com/Prytu/risk-advisor/cmd/riskadvisor/app/mock"
 	"github.com/Prytu/risk-advisor/pkg/flags"
@@ -69,6 +72,35 @@ func TestCommunicationWithSimulatorFailure(t *testing.T) {
 	assert.Equal(t, recorder.Body.Bytes(), expectedBodyBytes)
 }
 
+func TestSimulationFailureKeepsSimulator(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/advise", bodyToReadCloser([]*v1.Pod{}))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Body:       bodyToReadCloser(model.SchedulingResult{ErrorMessage: "pod default/old-pod not found"}),
+			Header:     defaultHeader(),
+		}, nil
+	}
+	httpClient := mocks.MockHTTPClient(simulatorResponse)
+	adviceService := New(defaults.SimulatorPort, clusterCommunicatorMock, *httpClient, defaults.StartupTimeout,
+		defaults.SimulatorNamespace, defaults.SimulatorPoolSize, true, "", "", nil, nil)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "simulation failed: pod default/old-pod not found")
+	// Long-lived simulator is released for the next request, not deleted
+	clusterCommunicatorMock.AssertNotCalled(t, "DeletePod", mock.Anything, mock.Anything)
+	assert.Len(t, adviceService.simulators.ready, 1)
+}
+
 func TestCreatingPodFailure(t *testing.T) {
 	request, _ := http.NewRequest("POST", "/advise", bodyToReadCloser([]*v1.Pod{}))
 
@@ -135,14 +167,429 @@ func TestUsersIncorrectRequest(t *testing.T) {
 	adviceService.ServeHTTP(recorder, request)
 
 	expectedBody := model.SchedulingResult{
-		ErrorMessage: fmt.Sprintf("Error communicating with simulator: %s", unmarshallingRequestBodyErrorMessage),
+		ErrorMessage: fmt.Sprintf("Invalid request: %s", unmarshallingRequestBodyErrorMessage),
 	}
 	expectedBodyBytes, err := json.Marshal(expectedBody)
 
 	assert.NoError(t, err)
-	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
 	assert.Contains(t, recorder.Header()["Content-Type"], "application/json")
 	assert.Equal(t, recorder.Body.Bytes(), expectedBodyBytes)
+	// Invalid requests do not lease (and so do not start or discard) simulators
+	clusterCommunicatorMock.AssertNotCalled(t, "CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInvalidResilienceRequestDoesNotDiscardSimulator(t *testing.T) {
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	simulatorResponse := createHTTPClientSuccessResponseFunc(http.StatusOK, []model.SchedulingResult{}, defaultHeader())
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	request, _ := http.NewRequest("POST", "/resilience?timeout=abc", bytes.NewReader(nil))
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "invalid timeout parameter")
+	clusterCommunicatorMock.AssertNotCalled(t, "CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	clusterCommunicatorMock.AssertNotCalled(t, "DeletePod", mock.Anything, mock.Anything)
+}
+
+func TestPodsToDeletePassedToSimulator(t *testing.T) {
+	userRequest := model.SimulatorRequest{
+		ToCreate: []*v1.Pod{{ObjectMeta: v1.ObjectMeta{Name: "new-pod"}}},
+		ToDelete: []*v1.Pod{{ObjectMeta: v1.ObjectMeta{Name: "old-pod"}}},
+	}
+	request, _ := http.NewRequest("POST", "/advise", bodyToReadCloser(userRequest))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	var simulatorRequest model.SimulatorRequest
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		err := json.NewDecoder(r.Body).Decode(&simulatorRequest)
+		assert.NoError(t, err)
+
+		return createHTTPClientSuccessResponseFunc(http.StatusOK, []model.SchedulingResult{}, defaultHeader())(r)
+	}
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, simulatorRequest.ToCreate, 1)
+	assert.Equal(t, "new-pod", simulatorRequest.ToCreate[0].Name)
+	assert.Len(t, simulatorRequest.ToDelete, 1)
+	assert.Equal(t, "old-pod", simulatorRequest.ToDelete[0].Name)
+}
+
+func TestTimeoutPassedToSimulator(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/advise?timeout=30", bodyToReadCloser([]*v1.Pod{}))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	var simulatorRequest model.SimulatorRequest
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		err := json.NewDecoder(r.Body).Decode(&simulatorRequest)
+		assert.NoError(t, err)
+
+		return createHTTPClientSuccessResponseFunc(http.StatusOK, []model.SchedulingResult{}, defaultHeader())(r)
+	}
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, int64(30), simulatorRequest.TimeoutSeconds)
+}
+
+func TestInvalidTimeoutsRejected(t *testing.T) {
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	simulatorResponse := createHTTPClientSuccessResponseFunc(http.StatusOK, []model.SchedulingResult{}, defaultHeader())
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+	adviceService.httpClient.Timeout = 60 * time.Second
+
+	for path, body := range map[string]interface{}{
+		"/advise?timeout=0":      []*v1.Pod{},
+		"/advise?timeout=60":     []*v1.Pod{},
+		"/advise":                map[string]interface{}{"toCreate": []*v1.Pod{}, "timeoutSeconds": -1},
+		"/resilience?timeout=-5": nil,
+		"/resilience":            model.ResilienceRequest{TimeoutSeconds: 90},
+	} {
+		request, _ := http.NewRequest("POST", path, bodyToReadCloser(body))
+		recorder := httptest.NewRecorder()
+		adviceService.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, path)
+		assert.Contains(t, recorder.Body.String(), "timeout", path)
+	}
+	clusterCommunicatorMock.AssertNotCalled(t, "CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCapacityProjectionReturned(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/advise?capacity=true", bodyToReadCloser([]*v1.Pod{}))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	expectedBody := model.SimulatorResponse{
+		Results: []*model.SchedulingResult{{PodName: "default/pod", Result: "Scheduled", NodeName: "node"}},
+		Capacity: []model.NodeCapacity{{
+			NodeName:        "node",
+			Allocatable:     model.Resources{MilliCPU: 1000, Memory: 1024, Pods: 10},
+			RequestedBefore: model.Resources{},
+			RequestedAfter:  model.Resources{MilliCPU: 100, Memory: 128, Pods: 1},
+		}},
+	}
+	var simulatorRequest model.SimulatorRequest
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		err := json.NewDecoder(r.Body).Decode(&simulatorRequest)
+		assert.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       bodyToReadCloser(expectedBody),
+			Header:     defaultHeader(),
+		}, nil
+	}
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	expectedBodyBytes, err := json.MarshalIndent(expectedBody, "", " ")
+
+	assert.NoError(t, err)
+	assert.True(t, simulatorRequest.Capacity)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, string(expectedBodyBytes), recorder.Body.String())
+}
+
+func TestNodeMutationsPassedToSimulator(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/advise", bytes.NewReader([]byte(`{"nodes": {"drain": ["node-a"]}}`)))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	expectedBody := model.SimulatorResponse{
+		Results: []*model.SchedulingResult{},
+		Evicted: []*model.SchedulingResult{{PodName: "default/pod", Result: "Scheduled", NodeName: "node-b"}},
+	}
+	var simulatorRequest model.SimulatorRequest
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		err := json.NewDecoder(r.Body).Decode(&simulatorRequest)
+		assert.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       bodyToReadCloser(expectedBody),
+			Header:     defaultHeader(),
+		}, nil
+	}
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	expectedBodyBytes, err := json.MarshalIndent(expectedBody, "", " ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	if assert.NotNil(t, simulatorRequest.Nodes) {
+		assert.Equal(t, []string{"node-a"}, simulatorRequest.Nodes.Drain)
+	}
+	assert.Equal(t, string(expectedBodyBytes), recorder.Body.String())
+}
+
+func TestResilienceRequestPassedToSimulator(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/resilience?domainLabel=zone&timeout=30", bytes.NewReader(nil))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	expectedBody := model.ResilienceResponse{
+		DomainLabel: "zone",
+		Domains: []model.DomainFailure{{
+			Domain:      "zone-a",
+			Nodes:       []string{"node-a"},
+			Results:     []*model.SchedulingResult{{PodName: "default/pod", Result: "FailedScheduling"}},
+			Unrecovered: []string{"Pod default/pod"},
+		}},
+		Unrecoverable: []string{"zone-a"},
+	}
+	var resilienceRequest model.ResilienceRequest
+	var simulatorPath string
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		simulatorPath = r.URL.Path
+		err := json.NewDecoder(r.Body).Decode(&resilienceRequest)
+		assert.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       bodyToReadCloser(expectedBody),
+			Header:     defaultHeader(),
+		}, nil
+	}
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	expectedBodyBytes, err := json.MarshalIndent(expectedBody, "", " ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "/resilience", simulatorPath)
+	assert.Equal(t, model.ResilienceRequest{DomainLabel: "zone", TimeoutSeconds: 30}, resilienceRequest)
+	assert.Equal(t, string(expectedBodyBytes), recorder.Body.String())
+}
+
+func snapshotSimulatorResponse(t *testing.T, simulatorRequest *model.SimulatorRequest) HttpClientResponseFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		err := json.NewDecoder(r.Body).Decode(simulatorRequest)
+		assert.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: bodyToReadCloser(model.SimulatorResponse{
+				Results:  []*model.SchedulingResult{{PodName: "default/pod", Result: "Scheduled", NodeName: "node"}},
+				Snapshot: &model.Snapshot{ResourceVersion: "7", Nodes: []v1.Node{{ObjectMeta: v1.ObjectMeta{Name: "node"}}}},
+			}),
+			Header: defaultHeader(),
+		}, nil
+	}
+}
+
+func TestSimulationBundleReturned(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/advise?snapshot=true", bodyToReadCloser([]*v1.Pod{}))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	var simulatorRequest model.SimulatorRequest
+	adviceService := createServiceWithMockHttpClient(snapshotSimulatorResponse(t, &simulatorRequest),
+		clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	var bundle model.SimulationBundle
+	err := json.Unmarshal(recorder.Body.Bytes(), &bundle)
+
+	assert.NoError(t, err)
+	assert.True(t, simulatorRequest.Snapshot)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, model.SimulationBundleVersion, bundle.Version)
+	assert.Equal(t, "7", bundle.Snapshot.ResourceVersion)
+	assert.Len(t, bundle.Snapshot.Nodes, 1)
+	assert.Equal(t, &simulatorRequest, bundle.Request)
+	assert.Nil(t, bundle.Response.Snapshot)
+	assert.Len(t, bundle.Response.Results, 1)
+}
+
+func TestSimulationBundleStored(t *testing.T) {
+	snapshotDir, err := ioutil.TempDir("", "snapshots")
+	assert.NoError(t, err)
+	defer os.RemoveAll(snapshotDir)
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	var simulatorRequest model.SimulatorRequest
+	httpClient := mocks.MockHTTPClient(snapshotSimulatorResponse(t, &simulatorRequest))
+	adviceService := New(defaults.SimulatorPort, clusterCommunicatorMock, *httpClient, defaults.StartupTimeout,
+		defaults.SimulatorNamespace, defaults.SimulatorPoolSize, false, "", snapshotDir, nil, nil)
+
+	request, _ := http.NewRequest("POST", "/advise", bodyToReadCloser([]*v1.Pod{}))
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	assert.True(t, simulatorRequest.Snapshot)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var results []model.SchedulingResult
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &results))
+	assert.Len(t, results, 1)
+
+	// Snapshot fetched only for the bundle is not returned with the full response either
+	request, _ = http.NewRequest("POST", "/advise?capacity=true", bodyToReadCloser([]*v1.Pod{}))
+	recorder = httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response model.SimulatorResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 1)
+	assert.Nil(t, response.Snapshot)
+
+	files, err := ioutil.ReadDir(snapshotDir)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 2) {
+		bundleJSON, err := ioutil.ReadFile(filepath.Join(snapshotDir, files[0].Name()))
+		assert.NoError(t, err)
+		var bundle model.SimulationBundle
+		assert.NoError(t, json.Unmarshal(bundleJSON, &bundle))
+		assert.Equal(t, "7", bundle.Snapshot.ResourceVersion)
+		assert.Len(t, bundle.Response.Results, 1)
+	}
+}
+
+func TestWorkloadResultsGrouped(t *testing.T) {
+	deployment := json.RawMessage(`{"kind": "Deployment", "metadata": {"name": "web"}, "spec": {"replicas": 2}}`)
+	request, _ := http.NewRequest("POST", "/advise", bodyToReadCloser([]json.RawMessage{deployment}))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		var simulatorRequest model.SimulatorRequest
+		err := json.NewDecoder(r.Body).Decode(&simulatorRequest)
+		assert.NoError(t, err)
+		assert.Len(t, simulatorRequest.ToCreate, 2)
+
+		var results []model.SchedulingResult
+		for _, pod := range simulatorRequest.ToCreate {
+			results = append(results, model.SchedulingResult{
+				PodName: model.PodKey(pod.Namespace, pod.Name),
+				Result:  "Scheduled",
+			})
+		}
+
+		return createHTTPClientSuccessResponseFunc(http.StatusOK, results, defaultHeader())(r)
+	}
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	var response model.SimulatorResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, response.Results, 2)
+	assert.Equal(t, []model.WorkloadResult{{
+		Kind:      "Deployment",
+		Name:      "default/web",
+		Replicas:  2,
+		Scheduled: 2,
+		Message:   "2 of 2 replicas schedulable",
+	}}, response.Workloads)
+}
+
+func TestYAMLRequestWithUnsupportedObjects(t *testing.T) {
+	manifest := "kind: Service\nmetadata:\n  name: web\n---\nkind: Pod\nmetadata:\n  name: web\n"
+	request, _ := http.NewRequest("POST", "/advise", ioutil.NopCloser(bytes.NewBufferString(manifest)))
+	request.Header.Set("Content-Type", "application/yaml")
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	var simulatorRequest model.SimulatorRequest
+	simulatorResponse := func(r *http.Request) (*http.Response, error) {
+		err := json.NewDecoder(r.Body).Decode(&simulatorRequest)
+		assert.NoError(t, err)
+
+		results := []model.SchedulingResult{{PodName: "default/web", Result: "Scheduled"}}
+		return createHTTPClientSuccessResponseFunc(http.StatusOK, results, defaultHeader())(r)
+	}
+	adviceService := createServiceWithMockHttpClient(simulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	var response model.SimulatorResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	if assert.Len(t, simulatorRequest.ToCreate, 1) {
+		assert.Equal(t, "web", simulatorRequest.ToCreate[0].Name)
+	}
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, []string{"Service default/web"}, response.Ignored)
+}
+
+func TestYAMLParseErrorsReported(t *testing.T) {
+	manifest := "kind: Pod\nmetadata:\n  name: web\n---\nkind: [\n"
+	request, _ := http.NewRequest("POST", "/advise", ioutil.NopCloser(bytes.NewBufferString(manifest)))
+
+	clusterCommunicatorMock := &mocks.KubernetesClientMock{}
+	clusterCommunicatorMock.
+		On("CreatePod", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("podIP", nil).
+		On("WaitUntilPodReady", mock.Anything, mock.Anything).Return(nil).
+		On("DeletePod", mock.Anything, mock.Anything).Return(nil)
+	ignoredSimulatorResponse := createHTTPClientSuccessResponseFunc(http.StatusOK, []model.SchedulingResult{}, defaultHeader())
+	adviceService := createServiceWithMockHttpClient(ignoredSimulatorResponse, clusterCommunicatorMock)
+
+	recorder := httptest.NewRecorder()
+	adviceService.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "error parsing request body: document 2")
 }
 
 func TestIncorrectSimulatorsResponse(t *testing.T) {
@@ -167,7 +614,8 @@ func TestIncorrectSimulatorsResponse(t *testing.T) {
 func createService(
 	clusterCommunicatorMock kubeClient.PodOperationHandler,
 ) *AdviceService {
-	return New(defaults.SimulatorPort, clusterCommunicatorMock, http.Client{}, defaults.StartupTimeout)
+	return New(defaults.SimulatorPort, clusterCommunicatorMock, http.Client{}, defaults.StartupTimeout,
+		defaults.SimulatorNamespace, defaults.SimulatorPoolSize, false, "", "", nil, nil)
 }
 
 type HttpClientResponseFunc func(*http.Request) (*http.Response, error)
@@ -177,7 +625,8 @@ func createServiceWithMockHttpClient(
 	clusterCommunicatorMock kubeClient.PodOperationHandler,
 ) *AdviceService {
 	httpClient := mocks.MockHTTPClient(simulatorResponseMockFunc)
-	return New(defaults.SimulatorPort, clusterCommunicatorMock, *httpClient, defaults.StartupTimeout)
+	return New(defaults.SimulatorPort, clusterCommunicatorMock, *httpClient, defaults.StartupTimeout,
+		defaults.SimulatorNamespace, defaults.SimulatorPoolSize, false, "", "", nil, nil)
 }
 
 func createHTTPClientSuccessResponseFunc(
@@ -1,35 +1,177 @@
 package app
 
-// TODO: move to ~config file
-
-import "k8s.io/client-go/1.5/pkg/api/v1"
-
-var simulatorPod = &v1.Pod{
-	ObjectMeta: v1.ObjectMeta{
-		Name: "simulator",
-	},
-	Spec: v1.PodSpec{
-		Containers: []v1.Container{{
-			Name:            "simulator",
-			Image:           "pposkrobko/simulator:v1.0.0",
-			ImagePullPolicy: v1.PullIfNotPresent,
-			Ports: []v1.ContainerPort{
-				{ContainerPort: 9998},
-				{ContainerPort: 9999},
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/util/yaml"
+)
+
+const (
+	simulatorContainerName = "simulator"
+	schedulerContainerName = "kubescheduler"
+	simulatorPodLabel      = "risk-advisor-simulator"
+)
+
+// Scheduler policy ConfigMap is mounted in the scheduler container, the policy is read from policy.cfg key
+const (
+	schedulerPolicyVolumeName = "scheduler-policy"
+	schedulerPolicyMountPath  = "/etc/kubernetes/scheduler-policy"
+	schedulerPolicyKey        = "policy.cfg"
+)
+
+// Reads the template of simulator pods from a YAML or JSON file with a Pod, e.g. mounted from a ConfigMap.
+// The template must contain a container named "simulator", arguments of the simulator are appended to its command.
+// Tolerations can be given either in the alpha annotation or in spec.tolerations, which is converted to it.
+func ReadSimulatorPodTemplate(filename string) (*v1.Pod, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading simulator pod template: %s", err)
+	}
+
+	data, err = yaml.ToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error converting simulator pod template to JSON: %s", err)
+	}
+
+	var template v1.Pod
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("error unmarshalling simulator pod template: %s", err)
+	}
+
+	// Tolerations are not a field of the pod spec in the API version used by the project
+	var spec struct {
+		Spec struct {
+			Tolerations json.RawMessage `json:"tolerations"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("error unmarshalling simulator pod template: %s", err)
+	}
+	if len(spec.Spec.Tolerations) > 0 && string(spec.Spec.Tolerations) != "null" {
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string)
+		}
+		template.Annotations[api.TolerationsAnnotationKey] = string(spec.Spec.Tolerations)
+	}
+
+	if simulatorContainer(&template) == nil {
+		return nil, fmt.Errorf("simulator pod template has no container named %s", simulatorContainerName)
+	}
+
+	return &template, nil
+}
+
+// Creates a simulator pod from the template, the template is not modified
+func newSimulatorPod(template *v1.Pod, name string, simulatorArgs []string) *v1.Pod {
+	pod := *template
+	pod.Name = name
+
+	pod.Labels = make(map[string]string, len(template.Labels)+1)
+	for key, value := range template.Labels {
+		pod.Labels[key] = value
+	}
+	pod.Labels["app"] = simulatorPodLabel
+
+	pod.Spec.Containers = append([]v1.Container(nil), template.Spec.Containers...)
+	container := simulatorContainer(&pod)
+	container.Command = append(append([]string(nil), container.Command...), simulatorArgs...)
+
+	return &pod
+}
+
+// Returns a copy of the template, in which the scheduler container uses the policy from the given ConfigMap
+// (in the namespace of simulator pods), like the real scheduler started with --policy-config-file.
+func WithSchedulerPolicy(template *v1.Pod, configMapName string) (*v1.Pod, error) {
+	pod := *template
+	pod.Spec.Containers = append([]v1.Container(nil), template.Spec.Containers...)
+
+	scheduler := container(&pod, schedulerContainerName)
+	if scheduler == nil {
+		return nil, fmt.Errorf("simulator pod template has no container named %s", schedulerContainerName)
+	}
+
+	pod.Spec.Volumes = append(append([]v1.Volume(nil), template.Spec.Volumes...), v1.Volume{
+		Name: schedulerPolicyVolumeName,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+			},
+		},
+	})
+	scheduler.VolumeMounts = append(append([]v1.VolumeMount(nil), scheduler.VolumeMounts...), v1.VolumeMount{
+		Name:      schedulerPolicyVolumeName,
+		MountPath: schedulerPolicyMountPath,
+		ReadOnly:  true,
+	})
+	appendArg(scheduler, fmt.Sprintf("--policy-config-file=%s/%s", schedulerPolicyMountPath, schedulerPolicyKey))
+
+	return &pod, nil
+}
+
+// Appends the argument to the command run by the container, either directly or by `sh -c`
+func appendArg(container *v1.Container, arg string) {
+	command := container.Command
+	switch {
+	case len(command) >= 2 && command[len(command)-2] == "-c":
+		container.Command = append(append([]string(nil), command[:len(command)-1]...),
+			fmt.Sprintf("%s %s", command[len(command)-1], arg))
+	case len(command) == 0 || len(container.Args) > 0:
+		container.Args = append(append([]string(nil), container.Args...), arg)
+	default:
+		container.Command = append(append([]string(nil), command...), arg)
+	}
+}
+
+func simulatorContainer(pod *v1.Pod) *v1.Container {
+	return container(pod, simulatorContainerName)
+}
+
+func container(pod *v1.Pod, name string) *v1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i]
+		}
+	}
+
+	return nil
+}
+
+// Template used if none is given
+func DefaultSimulatorPodTemplate() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Labels: map[string]string{
+				"app": simulatorPodLabel,
 			},
 		},
-			{
-				Name:  "kubescheduler",
-				Image: "gcr.io/google_containers/kube-scheduler:v1.4.6",
-				Command: []string{"/bin/sh", "-c",
-					"/usr/local/bin/kube-scheduler --master=127.0.0.1:9999 --leader-elect=false --kube-api-content-type application/json"},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:            simulatorContainerName,
+				Image:           "pposkrobko/simulator:v1.0.0",
+				Command:         []string{"/bin/simulator"},
+				ImagePullPolicy: v1.PullIfNotPresent,
+				Ports: []v1.ContainerPort{
+					{ContainerPort: 9998},
+					{ContainerPort: 9999},
+				},
 			},
-			{
-				Name:            "kubectl",
-				Image:           "gcr.io/google_containers/kubectl:v0.18.0-120-gaeb4ac55ad12b1-dirty",
-				ImagePullPolicy: "Always",
-				Args:            []string{"proxy", "-p", "8080"},
+				{
+					Name:  schedulerContainerName,
+					Image: "gcr.io/google_containers/kube-scheduler:v1.4.6",
+					Command: []string{"/usr/local/bin/kube-scheduler", "--master=127.0.0.1:9999", "--leader-elect=false",
+						"--kube-api-content-type=application/json"},
+				},
+				{
+					Name:            "kubectl",
+					Image:           "gcr.io/google_containers/kubectl:v0.18.0-120-gaeb4ac55ad12b1-dirty",
+					ImagePullPolicy: "Always",
+					Args:            []string{"proxy", "-p", "8080"},
+				},
 			},
 		},
-	},
+	}
 }
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/pkg/testutil"
+)
+
+func TestReadSimulatorPodTemplate(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "simulator-pod", `
+apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    team: platform
+spec:
+  serviceAccountName: risk-advisor-simulator
+  nodeSelector:
+    pool: system
+  tolerations:
+  - key: dedicated
+    operator: Equal
+    value: system
+    effect: NoSchedule
+  containers:
+  - name: simulator
+    image: registry.example.com/simulator:v1.1.0
+    command: ["/bin/simulator"]
+    resources:
+      requests:
+        cpu: 100m
+  - name: kubescheduler
+    image: registry.example.com/kube-scheduler:v1.4.6
+    command: ["/usr/local/bin/kube-scheduler", "--master=127.0.0.1:9999", "--leader-elect=false"]
+`)
+	defer os.Remove(filename)
+
+	template, err := ReadSimulatorPodTemplate(filename)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "risk-advisor-simulator", template.Spec.ServiceAccountName)
+	assert.Equal(t, map[string]string{"pool": "system"}, template.Spec.NodeSelector)
+	assert.Contains(t, template.Annotations[api.TolerationsAnnotationKey], `"key":"dedicated"`)
+	if assert.Len(t, template.Spec.Containers, 2) {
+		assert.Equal(t, "registry.example.com/simulator:v1.1.0", template.Spec.Containers[0].Image)
+		cpu := template.Spec.Containers[0].Resources.Requests[v1.ResourceCPU]
+		assert.Equal(t, int64(100), cpu.MilliValue())
+	}
+}
+
+func TestReadSimulatorPodTemplateWithoutSimulatorContainer(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "simulator-pod", `{"spec": {"containers": [{"name": "kubescheduler"}]}}`)
+	defer os.Remove(filename)
+
+	_, err := ReadSimulatorPodTemplate(filename)
+
+	assert.Error(t, err)
+}
+
+func TestNewSimulatorPodDoesNotModifyTemplate(t *testing.T) {
+	template := DefaultSimulatorPodTemplate()
+	template.Labels["team"] = "platform"
+
+	pod := newSimulatorPod(template, "simulator-abc", []string{"--long-lived"})
+
+	assert.Equal(t, "simulator-abc", pod.Name)
+	assert.Equal(t, map[string]string{"app": simulatorPodLabel, "team": "platform"}, pod.Labels)
+	assert.Equal(t, []string{"/bin/simulator", "--long-lived"}, simulatorContainer(pod).Command)
+	assert.Equal(t, "", template.Name)
+	assert.Equal(t, []string{"/bin/simulator"}, simulatorContainer(template).Command)
+	assert.Equal(t, v1.PullIfNotPresent, simulatorContainer(template).ImagePullPolicy)
+}
+
+func TestWithSchedulerPolicy(t *testing.T) {
+	template := DefaultSimulatorPodTemplate()
+
+	pod, err := WithSchedulerPolicy(template, "scheduler-policy")
+
+	assert.NoError(t, err)
+	if assert.Len(t, pod.Spec.Volumes, 1) {
+		assert.Equal(t, "scheduler-policy", pod.Spec.Volumes[0].ConfigMap.Name)
+	}
+	scheduler := container(pod, schedulerContainerName)
+	assert.Len(t, scheduler.VolumeMounts, 1)
+	assert.Equal(t, "--policy-config-file=/etc/kubernetes/scheduler-policy/policy.cfg",
+		scheduler.Command[len(scheduler.Command)-1])
+
+	assert.Empty(t, template.Spec.Volumes)
+	assert.Empty(t, container(template, schedulerContainerName).VolumeMounts)
+	assert.Equal(t, len(scheduler.Command)-1, len(container(template, schedulerContainerName).Command))
+}
+
+func TestWithSchedulerPolicyRunByShell(t *testing.T) {
+	template := DefaultSimulatorPodTemplate()
+	container(template, schedulerContainerName).Command = []string{"/bin/sh", "-c", "kube-scheduler --leader-elect=false"}
+
+	pod, err := WithSchedulerPolicy(template, "scheduler-policy")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/bin/sh", "-c",
+		"kube-scheduler --leader-elect=false --policy-config-file=/etc/kubernetes/scheduler-policy/policy.cfg"},
+		container(pod, schedulerContainerName).Command)
+}
+
+func TestWithSchedulerPolicyWithoutSchedulerContainer(t *testing.T) {
+	template := DefaultSimulatorPodTemplate()
+	template.Spec.Containers = template.Spec.Containers[:1]
+
+	_, err := WithSchedulerPolicy(template, "scheduler-policy")
+
+	assert.Error(t, err)
+}
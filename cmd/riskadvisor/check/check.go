@@ -0,0 +1,199 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/Prytu/risk-advisor/pkg/flags"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Exit codes of the check command
+const (
+	ExitSchedulable   = 0
+	ExitUnschedulable = 1
+	ExitError         = 2
+)
+
+// Sends manifests to risk-advisor, prints scheduling results and returns the exit code:
+// ExitUnschedulable if any pod would not be scheduled, ExitError if the check could not be performed.
+func Run(args []string, stdout, stderr io.Writer) int {
+	flagSet := flag.NewFlagSet("check", flag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	filenames := flagSet.StringSliceP("filename", "f", nil, "Manifest files with objects to check, - reads from standard input.")
+	server := flagSet.String("server", fmt.Sprintf("http://localhost:%s", defaults.RiskAdvisorUserPort), "Address of risk-advisor.")
+	timeout := flagSet.Int("timeout", 0, "Maximum duration in seconds of the simulation, risk-advisor's default is used if not set.")
+	requestTimeout := flagSet.Int("requestTimeout", defaults.RequestTimeout, "Maximum duration in seconds to wait for risk-advisor to respond.")
+
+	if err := flagSet.Parse(args); err != nil {
+		return ExitError
+	}
+	if len(*filenames) == 0 {
+		fmt.Fprintln(stderr, "Error: at least one manifest file has to be given with -f")
+		return ExitError
+	}
+
+	manifests, err := readManifests(*filenames)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return ExitError
+	}
+
+	httpClient := http.Client{Timeout: time.Duration(*requestTimeout) * time.Second}
+	response, err := sendAdviceRequest(httpClient, adviseUrl(*server, *timeout), manifests)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return ExitError
+	}
+
+	printResponse(stdout, response)
+
+	for _, result := range response.Results {
+		if result.Result != "Scheduled" {
+			return ExitUnschedulable
+		}
+	}
+
+	return ExitSchedulable
+}
+
+// Joins all files into a single stream of YAML documents
+func readManifests(filenames []string) ([]byte, error) {
+	var manifests bytes.Buffer
+
+	for _, filename := range filenames {
+		var data []byte
+		var err error
+		if filename == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(filename)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %s", filename, err)
+		}
+
+		manifests.Write(data)
+		manifests.WriteString("\n---\n")
+	}
+
+	return manifests.Bytes(), nil
+}
+
+func adviseUrl(server string, timeout int) string {
+	url := fmt.Sprintf("%s/advise", strings.TrimSuffix(server, "/"))
+	if timeout > 0 {
+		url = fmt.Sprintf("%s?timeout=%d", url, timeout)
+	}
+
+	return url
+}
+
+func sendAdviceRequest(httpClient http.Client, url string, manifests []byte) (*model.SimulatorResponse, error) {
+	resp, err := httpClient.Post(url, "application/yaml", bytes.NewReader(manifests))
+	if err != nil {
+		return nil, fmt.Errorf("error sending request to risk-advisor: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading risk-advisor response: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResult model.SchedulingResult
+		if err := json.Unmarshal(body, &errorResult); err == nil && errorResult.ErrorMessage != "" {
+			return nil, fmt.Errorf("risk-advisor responded with error: %s", errorResult.ErrorMessage)
+		}
+		return nil, fmt.Errorf("risk-advisor responded with status %s", resp.Status)
+	}
+
+	// Response is either a table of results or an object with results grouped per workload
+	var response model.SimulatorResponse
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		err = json.Unmarshal(body, &response.Results)
+	} else {
+		err = json.Unmarshal(body, &response)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling risk-advisor response: %s", err)
+	}
+
+	return &response, nil
+}
+
+func printResponse(w io.Writer, response *model.SimulatorResponse) {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "POD\tRESULT\tNODE\tREASON")
+	for _, result := range response.Results {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", result.PodName, result.Result, result.NodeName, reason(result))
+	}
+	table.Flush()
+
+	if len(response.Workloads) > 0 {
+		fmt.Fprintln(w)
+		table = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(table, "WORKLOAD\tNAME\tSCHEDULABLE")
+		for _, workload := range response.Workloads {
+			fmt.Fprintf(table, "%s\t%s\t%d/%d\n", workload.Kind, workload.Name, workload.Scheduled, workload.Replicas)
+		}
+		table.Flush()
+	}
+
+	if len(response.Ignored) > 0 {
+		fmt.Fprintf(w, "\nIgnored objects: %s\n", strings.Join(response.Ignored, ", "))
+	}
+}
+
+// Failed predicates are summarized with the number of nodes they failed on, e.g. "Insufficient cpu (3 nodes)",
+// otherwise the first line of the message is used. Scheduled pods list the pods they preempt.
+func reason(result *model.SchedulingResult) string {
+	if result.Result == "Scheduled" {
+		if len(result.PreemptedPods) > 0 {
+			return fmt.Sprintf("preempts %s", strings.Join(result.PreemptedPods, ", "))
+		}
+		return ""
+	}
+
+	nodeCounts := result.FailedPredicateCounts
+	if len(result.FailedPredicates) > 0 {
+		nodeCounts = make(map[string]int)
+		for _, reasons := range result.FailedPredicates {
+			for _, reason := range reasons {
+				nodeCounts[reason]++
+			}
+		}
+	}
+
+	if len(nodeCounts) > 0 {
+		var reasons []string
+		for reason, count := range nodeCounts {
+			reasons = append(reasons, fmt.Sprintf("%s (%d %s)", reason, count, pluralize("node", count)))
+		}
+		sort.Strings(reasons)
+
+		return strings.Join(reasons, ", ")
+	}
+
+	return strings.SplitN(strings.TrimSpace(result.Message), "\n", 2)[0]
+}
+
+func pluralize(word string, count int) string {
+	if count == 1 {
+		return word
+	}
+
+	return word + "s"
+}
@@ -0,0 +1,105 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+	"github.com/Prytu/risk-advisor/pkg/testutil"
+)
+
+const manifest = `kind: Pod
+metadata:
+  name: web
+`
+
+func riskAdvisorStub(t *testing.T, statusCode int, response interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "/advise", r.URL.Path)
+		assert.Equal(t, "application/yaml", r.Header.Get("Content-Type"))
+		assert.Contains(t, string(body), manifest)
+
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestAllPodsSchedulable(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "manifest", manifest)
+	defer os.Remove(filename)
+	server := riskAdvisorStub(t, http.StatusOK, []model.SchedulingResult{
+		{PodName: "default/web", Result: "Scheduled", NodeName: "node-1"},
+	})
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-f", filename, "--server", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, ExitSchedulable, exitCode)
+	assert.Contains(t, stdout.String(), "default/web  Scheduled  node-1")
+}
+
+func TestUnschedulablePodFailsCheck(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "manifest", manifest)
+	defer os.Remove(filename)
+	server := riskAdvisorStub(t, http.StatusOK, model.SimulatorResponse{
+		Results: []*model.SchedulingResult{{
+			PodName: "default/web",
+			Result:  "FailedScheduling",
+			FailedPredicates: map[string][]string{
+				"node-1": {"Insufficient cpu"},
+				"node-2": {"Insufficient cpu", "MatchNodeSelector"},
+			},
+		}},
+		Workloads: []model.WorkloadResult{{Kind: "Deployment", Name: "default/web", Replicas: 1}},
+	})
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-f", filename, "--server", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, ExitUnschedulable, exitCode)
+	assert.Contains(t, stdout.String(), "Insufficient cpu (2 nodes), MatchNodeSelector (1 node)")
+	assert.Contains(t, stdout.String(), "Deployment  default/web  0/1")
+}
+
+func TestFailedPredicateCountsReported(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "manifest", manifest)
+	defer os.Remove(filename)
+	server := riskAdvisorStub(t, http.StatusOK, model.SimulatorResponse{
+		Results: []*model.SchedulingResult{{
+			PodName:               "default/web",
+			Result:                "FailedScheduling",
+			FailedPredicateCounts: map[string]int{"Insufficient cpu": 1, "node(s) didn't match node selector": 2},
+		}},
+	})
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-f", filename, "--server", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, ExitUnschedulable, exitCode)
+	assert.Contains(t, stdout.String(), "Insufficient cpu (1 node), node(s) didn't match node selector (2 nodes)")
+}
+
+func TestRiskAdvisorErrorReported(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "manifest", manifest)
+	defer os.Remove(filename)
+	server := riskAdvisorStub(t, http.StatusInternalServerError, model.SchedulingResult{ErrorMessage: "simulator failed"})
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-f", filename, "--server", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, ExitError, exitCode)
+	assert.Contains(t, stderr.String(), "simulator failed")
+}
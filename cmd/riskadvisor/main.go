@@ -3,34 +3,98 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	flag "github.com/spf13/pflag"
 
 	"github.com/Prytu/risk-advisor/cmd/riskadvisor/app"
+	"github.com/Prytu/risk-advisor/cmd/riskadvisor/check"
 	"github.com/Prytu/risk-advisor/pkg/flags"
 	"github.com/Prytu/risk-advisor/pkg/kubeClient"
 	log "github.com/Sirupsen/logrus"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(check.Run(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	simulatorPort := flag.String("simulator", defaults.SimulatorPort, "Port on which simulator pod listens for requests")
 	port := flag.String("port", defaults.RiskAdvisorUserPort, "Port on which risk-advisors listens for users requests")
 	simulatorStartupTimeout := flag.Int("startupTimeout", defaults.StartupTimeout, "Maximum duration in seconds to wait for simulator pod to start running.")
 	simulatorRequestTimeout := flag.Int("requestTimeout", defaults.RequestTimeout, "Maximum duration in seconds to wait for simulator to respond to schedluing request.")
+	simulatorNamespace := flag.String("simulatorNamespace", defaults.SimulatorNamespace, "Namespace in which simulator pods are created.")
+	simulatorPoolSize := flag.Int("simulators", defaults.SimulatorPoolSize, "Maximum number of simulator pods, i.e. number of advice requests handled concurrently.")
+	reuseSimulators := flag.Bool("reuseSimulators", false, "Run long-lived simulators, which fetch the cluster state for each request instead of being restarted.")
+	namespaces := flag.String("namespaces", "", "Comma separated list of namespaces included in the simulated cluster state, all namespaces by default.")
+	snapshotDir := flag.String("snapshotDir", "", "Directory in which a replayable bundle of the cluster snapshot, request and results of every simulation is stored.")
+	simulatorPodTemplate := flag.String("simulatorPodTemplate", "", "YAML or JSON file with the Pod used as the template of simulator pods, e.g. mounted from a ConfigMap.")
+	schedulerPolicyConfigMap := flag.String("schedulerPolicyConfigMap", "", "Name of the ConfigMap in simulatorNamespace with the scheduler policy (policy.cfg key) used by simulated schedulers.")
+	schedulerPolicyFile := flag.String("schedulerPolicyFile", "", "Scheduler policy file used by schedulers of local simulators.")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file, used instead of the in-cluster configuration, e.g. when risk-advisor runs on a workstation.")
+	context := flag.String("context", "", "Name of the kubeconfig context to use.")
+	localSimulator := flag.String("localSimulator", "", "Path to the simulator binary. If set, simulators are started as local processes instead of pods.")
+	schedulerBinary := flag.String("schedulerBinary", "kube-scheduler", "Path to the kube-scheduler binary started by local simulators.")
+	prewarmSimulators := flag.Bool("prewarm", false, "Keep simulator pods running before requests come. Note that the cluster state is fetched when a simulator starts.")
 
 	flag.Parse()
 
+	if *simulatorPoolSize < 1 {
+		log.Fatalf("Invalid number of simulators %d, at least one simulator is needed to handle requests", *simulatorPoolSize)
+	}
+
 	kcHttpClient := http.Client{Timeout: time.Duration(*simulatorRequestTimeout) * time.Second}
-	kubernetesClient, err := kubeClient.New(kcHttpClient)
+	kubernetesClient, err := kubeClient.New(kcHttpClient, *kubeconfig, *context)
 	if err != nil {
 		log.Fatalf("Failed to communicate with cluster when building kubeClient: %e\n", err)
 	}
 
+	podTemplate := app.DefaultSimulatorPodTemplate()
+	if *simulatorPodTemplate != "" {
+		podTemplate, err = app.ReadSimulatorPodTemplate(*simulatorPodTemplate)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to read simulator pod template")
+		}
+	}
+	if *schedulerPolicyConfigMap != "" {
+		podTemplate, err = app.WithSchedulerPolicy(podTemplate, *schedulerPolicyConfigMap)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to configure scheduler policy")
+		}
+	}
+
+	var localSimulators *app.LocalSimulators
+	if *localSimulator != "" {
+		localSimulators = &app.LocalSimulators{
+			SimulatorBinary:     *localSimulator,
+			SchedulerBinary:     *schedulerBinary,
+			SchedulerPolicyFile: *schedulerPolicyFile,
+			Kubeconfig:          *kubeconfig,
+			Context:             *context,
+		}
+	}
+
 	raHttpCient := http.Client{Timeout: time.Duration(*simulatorRequestTimeout) * time.Second}
-	riskAdvisor := app.New(*simulatorPort, kubernetesClient, raHttpCient, *simulatorStartupTimeout)
+	riskAdvisor := app.New(*simulatorPort, kubernetesClient, raHttpCient, *simulatorStartupTimeout, *simulatorNamespace,
+		*simulatorPoolSize, *reuseSimulators, *namespaces, *snapshotDir, podTemplate, localSimulators)
+	if *prewarmSimulators {
+		riskAdvisor.PrewarmSimulators()
+	}
+
+	// Do not leave idle simulator pods behind when risk-advisor is stopped
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		riskAdvisor.Close()
+		os.Exit(0)
+	}()
 
-	log.Printf("Starting risk-advisor with:\n\t- port: %v\n\t- simulator port: %v", *port, *simulatorPort)
+	log.Printf("Starting risk-advisor with:\n\t- port: %v\n\t- simulator port: %v\n\t- simulators: %v",
+		*port, *simulatorPort, *simulatorPoolSize)
 
 	http.ListenAndServe(fmt.Sprintf(":%s", *port), riskAdvisor)
 }
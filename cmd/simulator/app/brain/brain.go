@@ -7,16 +7,23 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"k8s.io/client-go/1.5/pkg/api/unversioned"
 	"k8s.io/client-go/1.5/pkg/api/v1"
 	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/fields"
 
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/model"
 )
 
-// TODO: for now we use pod.Name to identify pods. Maybe use uid or something like that instead?
+// Watches of pods assigned to nodes are counted separately from watches of pending pods
+const scheduledPodsResource = "scheduledpods"
+
+// Resources the scheduler has to watch before pods to schedule are added, volume predicates fail for pods
+// whose PVCs or PVs are not in the scheduler's cache yet
+var syncedResources = []string{"nodes", scheduledPodsResource, "persistentvolumes", "persistentvolumeclaims"}
+
 type Brain struct {
 	// Snapshot of the state of the cluster
 	state *state.ClusterState
@@ -24,26 +31,36 @@ type Brain struct {
 	// Channel that will send scheduling events to Simulator
 	eventChannel chan<- *v1.Event
 
-	nodesMutex            *sync.Mutex
-	isNodesRequestHandled bool
+	// Scheduler lists a resource before it starts watching it, so once it watches nodes, scheduled pods, PVs
+	// and PVCs of the current state, its cache is filled and pending pods can be scheduled. See ForceRelist.
+	// With many schedulers, each of them is expected to start one watch of each kind. Requests do not identify
+	// schedulers, so watches are only counted: a re-watch (e.g. after 410 Gone) counts as another scheduler,
+	// and a scheduler that is not running keeps the brain from syncing.
+	syncMutex      sync.Mutex
+	synced         chan struct{}
+	schedulerCount int
+	// Map resource (see syncedResources) to the number of its watches started since the last relist
+	watches map[string]int
+
+	preemptions *preemptions
 }
 
-func New(state *state.ClusterState, eventChannel chan<- *v1.Event) *Brain {
-	mutex := &sync.Mutex{}
-	mutex.Lock()
-
+// Scheduler count is the number of schedulers talking to the brain
+func New(state *state.ClusterState, eventChannel chan<- *v1.Event, schedulerCount int) *Brain {
 	return &Brain{
-		state:                 state,
-		eventChannel:          eventChannel,
-		nodesMutex:            mutex,
-		isNodesRequestHandled: false,
+		state:          state,
+		eventChannel:   eventChannel,
+		synced:         make(chan struct{}),
+		schedulerCount: schedulerCount,
+		watches:        make(map[string]int),
+		preemptions:    newPreemptions(),
 	}
 }
 
-func (b *Brain) GetPod(podName string) (*v1.Pod, error) {
-	pod, ok := b.state.GetPod(podName)
+func (b *Brain) GetPod(namespace, podName string) (*v1.Pod, error) {
+	pod, ok := b.state.GetPod(namespace, podName)
 	if !ok {
-		return nil, fmt.Errorf("no podname with name %s in state", podName)
+		return nil, fmt.Errorf("no pod with name %s in namespace %s in state", podName, namespace)
 	}
 
 	return &pod, nil
@@ -62,8 +79,8 @@ func (b *Brain) GetPods(fieldSelector string) *v1.PodList {
 		log.Printf("Unexpected GET pods field selector: %s", fieldSelector)
 	}
 
-	pods = b.state.GetPods(filter)
-	resourceVersion := strconv.FormatInt(int64(b.state.GetResourceVersion()), 10)
+	pods, rv := b.state.ListPods(filter)
+	resourceVersion := strconv.FormatInt(rv, 10)
 
 	podList := &v1.PodList{
 		TypeMeta: unversioned.TypeMeta{
@@ -77,18 +94,16 @@ func (b *Brain) GetPods(fieldSelector string) *v1.PodList {
 		Items: pods,
 	}
 
-	b.nodesMutex.Lock()
-	b.nodesMutex.Unlock()
-	// Temporary solution: sleep 1 second to make sure response to nodes request is delivered to client
-	// TODO: Fix
-	time.Sleep(time.Second)
-
 	return podList
 }
 
+func (b *Brain) GetResourceVersion() int64 {
+	return b.state.GetResourceVersion()
+}
+
 func (b *Brain) GetNodes() *v1.NodeList {
-	nodes := b.state.GetNodes()
-	resourceVersion := strconv.FormatInt(int64(b.state.GetResourceVersion()), 10)
+	nodes, rv := b.state.ListNodes()
+	resourceVersion := strconv.FormatInt(rv, 10)
 
 	nodeList := &v1.NodeList{
 		TypeMeta: unversioned.TypeMeta{
@@ -102,44 +117,167 @@ func (b *Brain) GetNodes() *v1.NodeList {
 		Items: nodes,
 	}
 
-	if !b.isNodesRequestHandled {
-		b.isNodesRequestHandled = true
-		b.nodesMutex.Unlock()
-	}
-
 	return nodeList
 }
 
 // TODO: Make a 'generic' function for those functions
 func (b *Brain) GetPvcs() *v1.PersistentVolumeClaimList {
-	return b.state.Pvcs
+	return b.state.GetPvcs()
 }
 
 func (b *Brain) GetPvs() *v1.PersistentVolumeList {
-	return b.state.Pvs
+	return b.state.GetPvs()
 }
 
 func (b *Brain) GetReplicasets() *v1beta1.ReplicaSetList {
-	return b.state.Replicasets
+	return b.state.GetReplicasets()
 }
 
 func (b *Brain) GetServices() *v1.ServiceList {
-	return b.state.Services
+	return b.state.GetServices()
 }
 
 func (b *Brain) GetReplicationControllers() *v1.ReplicationControllerList {
-	return b.state.ReplicationControllers
+	return b.state.GetReplicationControllers()
+}
+
+func (b *Brain) GetStatefulSets() []model.OpaqueObject {
+	return b.state.GetStatefulSets()
+}
+
+func (b *Brain) GetPodDisruptionBudgets() []model.OpaqueObject {
+	return b.state.GetPodDisruptionBudgets()
+}
+
+func (b *Brain) GetAllocatableResources() map[string]model.Resources {
+	return b.state.GetAllocatableResources()
 }
 
-func (b *Brain) AddPodToState(pod v1.Pod) {
+func (b *Brain) GetRequestedResources() map[string]model.Resources {
+	return b.state.GetRequestedResources()
+}
+
+func (b *Brain) Snapshot() *model.Snapshot {
+	return b.state.Snapshot()
+}
+
+func (b *Brain) ResetState(fresh *state.ClusterState) {
+	b.state.Reset(fresh)
+}
+
+func (b *Brain) AddPodToState(pod v1.Pod) error {
 	resourceVersion := b.state.GetResourceVersion()
 
 	updateNewPodData(&pod, resourceVersion)
-	b.state.AddPod(pod)
+	if err := b.resolvePriority(&pod); err != nil {
+		return fmt.Errorf("error adding pod %s to State: %s", model.PodKey(pod.Namespace, pod.Name), err)
+	}
+	if !b.state.AddPod(pod) {
+		return fmt.Errorf("error adding pod to State: pod %s already exists, delete it first to replace it",
+			model.PodKey(pod.Namespace, pod.Name))
+	}
+
+	return nil
 }
 
-func (b *Brain) Watchers() []byte {
-	return []byte("")
+func (b *Brain) RemovePodFromState(namespace, podName string) error {
+	if !b.state.DeletePod(namespace, podName) {
+		return fmt.Errorf("error removing pod from State: pod with name %s not found in namespace %s", podName, namespace)
+	}
+
+	return nil
+}
+
+// Starts watching the resource after given resource version. Pods can be additionally filtered by field selector.
+func (b *Brain) Watch(resource, resourceVersion, fieldSelector string) (*state.Watcher, error) {
+	var rv int64
+	if resourceVersion != "" {
+		var err error
+		rv, err = strconv.ParseInt(resourceVersion, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resourceVersion %s: %s", resourceVersion, err)
+		}
+	}
+
+	selector := fields.Everything()
+	filter := state.AllObjectsFilter
+	if fieldSelector != "" {
+		var err error
+		selector, err = fields.ParseSelector(fieldSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fieldSelector %s: %s", fieldSelector, err)
+		}
+		if resource == "pods" {
+			filter = state.PodFieldSelectorFilter(selector)
+		}
+	}
+
+	b.syncMutex.Lock()
+	defer b.syncMutex.Unlock()
+
+	watcher, err := b.state.Watch(resource, rv, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resource {
+	case "pods":
+		if nodeName, ok := selector.RequiresExactMatch("spec.nodeName"); !ok || nodeName != "" {
+			b.watches[scheduledPodsResource]++
+		}
+	default:
+		b.watches[resource]++
+	}
+
+	if b.watchesSynced() && !b.isSynced() {
+		close(b.synced)
+	}
+
+	return watcher, nil
+}
+
+// Makes the scheduler list all resources again, so that its cache contains the current state.
+// Should be called after the state is changed and before pods to schedule are added to it.
+func (b *Brain) ForceRelist() {
+	b.syncMutex.Lock()
+	defer b.syncMutex.Unlock()
+
+	b.state.ResetWatchers()
+
+	if b.isSynced() {
+		b.synced = make(chan struct{})
+	}
+	b.watches = make(map[string]int)
+}
+
+// Returns a channel that is closed once schedulers have listed nodes, scheduled pods, PVs and PVCs
+// of the current state.
+func (b *Brain) SchedulerSynced() <-chan struct{} {
+	b.syncMutex.Lock()
+	defer b.syncMutex.Unlock()
+
+	return b.synced
+}
+
+// Must be called with syncMutex locked.
+func (b *Brain) watchesSynced() bool {
+	for _, resource := range syncedResources {
+		if b.watches[resource] < b.schedulerCount {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Must be called with syncMutex locked.
+func (b *Brain) isSynced() bool {
+	select {
+	case <-b.synced:
+		return true
+	default:
+		return false
+	}
 }
 
 func (b *Brain) Event(event *v1.Event) []byte {
@@ -156,16 +294,17 @@ func (b *Brain) Event(event *v1.Event) []byte {
 
 // TODO: maybe generate binding response instead of sending the same for each? (check if it is necessary)
 func (b *Brain) Binding(binding *v1.Binding) ([]byte, error) {
+	namespace := binding.ObjectMeta.Namespace
 	podName := binding.ObjectMeta.Name
 	nodeName := binding.Target.Name
 
-	pod, ok := b.state.GetPod(podName)
+	pod, ok := b.state.GetPod(namespace, podName)
 	if !ok {
-		return nil, fmt.Errorf("error fetching pod from State: pod with name %s not found", podName)
+		return nil, fmt.Errorf("error fetching pod from State: pod with name %s not found in namespace %s", podName, namespace)
 	}
 
 	bindPodToNode(&pod, nodeName)
-	b.state.UpdatePod(podName, pod)
+	b.state.UpdatePod(pod)
 
 	// here we just bind the pod to node, the scheduling result will be sent as an Event and processed there
 
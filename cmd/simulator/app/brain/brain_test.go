@@ -0,0 +1,37 @@
+package brain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state/fieldselectors"
+)
+
+func TestSchedulerSyncedAfterWatchingVolumes(t *testing.T) {
+	b := newNodesTestBrain(t, nil, nil)
+	b.ForceRelist()
+
+	for _, resource := range []string{"nodes", "pods", "persistentvolumes"} {
+		selector := ""
+		if resource == "pods" {
+			selector = fieldselectors.AssignedNonTerminatedPods
+		}
+		_, err := b.Watch(resource, "0", selector)
+		assert.NoError(t, err)
+	}
+	assert.False(t, isClosed(b.SchedulerSynced()))
+
+	_, err := b.Watch("persistentvolumeclaims", "0", "")
+	assert.NoError(t, err)
+	assert.True(t, isClosed(b.SchedulerSynced()))
+}
+
+func isClosed(channel <-chan struct{}) bool {
+	select {
+	case <-channel:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,188 @@
+package brain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+const (
+	mirrorPodAnnotationKey = "kubernetes.io/config.mirror"
+	createdByAnnotationKey = "kubernetes.io/created-by"
+)
+
+// Applies node mutations to the state. Pods of removed and drained nodes are removed from the state
+// and returned unassigned, so that they can be scheduled again. DaemonSet and mirror pods are not moved,
+// they stay on drained nodes and disappear with removed nodes.
+func (b *Brain) ApplyNodeMutations(mutations *model.NodeMutations) ([]v1.Pod, error) {
+	if mutations.IsEmpty() {
+		return nil, nil
+	}
+
+	for _, template := range mutations.Add {
+		nodes, err := b.nodesFromTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			if !b.state.AddNode(node) {
+				return nil, fmt.Errorf("error adding node %s: node already exists", node.Name)
+			}
+		}
+	}
+
+	cordoned := append(append([]string(nil), mutations.Cordon...), mutations.Drain...)
+	for _, nodeName := range cordoned {
+		node, ok := b.state.GetNode(nodeName)
+		if !ok {
+			return nil, fmt.Errorf("error cordoning node %s: node not found", nodeName)
+		}
+		node.Spec.Unschedulable = true
+		b.state.UpdateNode(node)
+	}
+
+	var evicted []v1.Pod
+	for _, nodeName := range mutations.Drain {
+		evicted = append(evicted, b.evictPods(nodeName, false)...)
+	}
+
+	for _, nodeName := range mutations.Remove {
+		if !b.state.DeleteNode(nodeName) {
+			return nil, fmt.Errorf("error removing node %s: node not found", nodeName)
+		}
+		evicted = append(evicted, b.evictPods(nodeName, true)...)
+	}
+
+	return evicted, nil
+}
+
+// Removes pods of the node from the state and returns the ones that have to be scheduled again, sorted by key
+func (b *Brain) evictPods(nodeName string, nodeRemoved bool) []v1.Pod {
+	pods := b.state.GetPods(func(pod *v1.Pod) bool {
+		return pod.Spec.NodeName == nodeName && state.AssignedNonTerminatedPodFilter(pod)
+	})
+	sort.Sort(podsByKey(pods))
+
+	var evicted []v1.Pod
+	for _, pod := range pods {
+		movable := !isDaemonSetPod(&pod) && !isMirrorPod(&pod)
+		if !movable && !nodeRemoved {
+			continue
+		}
+
+		b.state.DeletePod(pod.Namespace, pod.Name)
+		if movable {
+			pod.Spec.NodeName = ""
+			evicted = append(evicted, pod)
+		}
+	}
+
+	return evicted
+}
+
+func (b *Brain) nodesFromTemplate(template model.NodeTemplate) ([]v1.Node, error) {
+	var base v1.Node
+	switch {
+	case template.Node != nil:
+		base = *template.Node
+	case template.CopyOf != "":
+		node, ok := b.state.GetNode(template.CopyOf)
+		if !ok {
+			return nil, fmt.Errorf("error adding nodes: node %s to copy not found", template.CopyOf)
+		}
+		// Taints are kept, they are annotations in the API version used by the project
+		base = node
+		base.Name = ""
+		base.Spec = v1.NodeSpec{}
+	default:
+		return nil, fmt.Errorf("error adding nodes: template has neither node nor copyOf")
+	}
+
+	count := template.Count
+	if count <= 0 {
+		count = 1
+	}
+	if count > model.MaxTemplateNodes {
+		return nil, fmt.Errorf("error adding nodes: count %d of template %s is greater than %d",
+			count, template.Name(), model.MaxTemplateNodes)
+	}
+
+	baseName := template.Name()
+	if count == 1 && base.Name != "" {
+		return []v1.Node{newSyntheticNode(base, baseName)}, nil
+	}
+
+	nodes := make([]v1.Node, count)
+	suffix := 1
+	for i := range nodes {
+		name := fmt.Sprintf("%s-%d", baseName, suffix)
+		for b.hasNode(name) {
+			suffix++
+			name = fmt.Sprintf("%s-%d", baseName, suffix)
+		}
+		suffix++
+		nodes[i] = newSyntheticNode(base, name)
+	}
+
+	return nodes, nil
+}
+
+func (b *Brain) hasNode(name string) bool {
+	_, ok := b.state.GetNode(name)
+	return ok
+}
+
+// Node based on the template, which the scheduler sees as a ready node
+func newSyntheticNode(template v1.Node, name string) v1.Node {
+	node := template
+	node.Name = name
+	node.UID = ""
+	node.SelfLink = fmt.Sprintf("/api/v1/nodes/%s", name)
+	node.CreationTimestamp = unversioned.Now()
+
+	node.Labels = make(map[string]string, len(template.Labels)+1)
+	for key, value := range template.Labels {
+		node.Labels[key] = value
+	}
+	node.Labels[unversioned.LabelHostname] = name
+
+	if len(node.Status.Allocatable) == 0 {
+		node.Status.Allocatable = node.Status.Capacity
+	}
+	node.Status.Conditions = []v1.NodeCondition{{
+		Type:   v1.NodeReady,
+		Status: v1.ConditionTrue,
+	}}
+	node.Status.Addresses = nil
+
+	return node
+}
+
+type podsByKey []v1.Pod
+
+func (p podsByKey) Len() int      { return len(p) }
+func (p podsByKey) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p podsByKey) Less(i, j int) bool {
+	return model.PodKey(p[i].Namespace, p[i].Name) < model.PodKey(p[j].Namespace, p[j].Name)
+}
+
+func isMirrorPod(pod *v1.Pod) bool {
+	_, ok := pod.Annotations[mirrorPodAnnotationKey]
+	return ok
+}
+
+func isDaemonSetPod(pod *v1.Pod) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return true
+		}
+	}
+
+	return strings.Contains(pod.Annotations[createdByAnnotationKey], `"kind":"DaemonSet"`)
+}
@@ -0,0 +1,110 @@
+package brain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/resource"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/kubeClient"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+func newNodesTestBrain(t *testing.T, nodes []v1.Node, pods []v1.Pod) *Brain {
+	fetcher := kubeClient.NewSnapshotFetcher(&model.Snapshot{Nodes: nodes, Pods: pods})
+	clusterState, err := state.InitState(fetcher, nil)
+	assert.NoError(t, err)
+
+	return New(clusterState, make(chan *v1.Event, 10), 1)
+}
+
+func newAssignedPod(name, nodeName string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: v1.NamespaceDefault, Name: name},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func TestDrainKeepsDaemonSetPods(t *testing.T) {
+	daemonSetPod := newAssignedPod("fluentd", "node")
+	daemonSetPod.OwnerReferences = []v1.OwnerReference{{Kind: "DaemonSet", Name: "fluentd"}}
+	b := newNodesTestBrain(t, []v1.Node{{ObjectMeta: v1.ObjectMeta{Name: "node"}}},
+		[]v1.Pod{newAssignedPod("web", "node"), daemonSetPod})
+
+	evicted, err := b.ApplyNodeMutations(&model.NodeMutations{Drain: []string{"node"}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, evicted, 1) {
+		assert.Equal(t, "web", evicted[0].Name)
+		assert.Empty(t, evicted[0].Spec.NodeName)
+	}
+	_, err = b.GetPod(v1.NamespaceDefault, "fluentd")
+	assert.NoError(t, err)
+	_, err = b.GetPod(v1.NamespaceDefault, "web")
+	assert.Error(t, err)
+
+	node, _ := b.state.GetNode("node")
+	assert.True(t, node.Spec.Unschedulable)
+}
+
+func TestRemovedNodeTakesDaemonSetPods(t *testing.T) {
+	daemonSetPod := newAssignedPod("fluentd", "node")
+	daemonSetPod.OwnerReferences = []v1.OwnerReference{{Kind: "DaemonSet", Name: "fluentd"}}
+	b := newNodesTestBrain(t, []v1.Node{{ObjectMeta: v1.ObjectMeta{Name: "node"}}},
+		[]v1.Pod{newAssignedPod("web", "node"), daemonSetPod})
+
+	evicted, err := b.ApplyNodeMutations(&model.NodeMutations{Remove: []string{"node"}})
+
+	assert.NoError(t, err)
+	assert.Len(t, evicted, 1)
+	_, err = b.GetPod(v1.NamespaceDefault, "fluentd")
+	assert.Error(t, err)
+	assert.Empty(t, b.GetNodes().Items)
+
+	_, err = b.ApplyNodeMutations(&model.NodeMutations{Cordon: []string{"node"}})
+	assert.Error(t, err)
+}
+
+func TestNodesAddedFromTemplate(t *testing.T) {
+	b := newNodesTestBrain(t, nil, nil)
+	template := &v1.Node{
+		ObjectMeta: v1.ObjectMeta{Name: "m5-xlarge", Labels: map[string]string{"instance-type": "m5.xlarge"}},
+		Status: v1.NodeStatus{
+			Capacity: v1.ResourceList{v1.ResourcePods: *resource.NewQuantity(110, resource.DecimalSI)},
+		},
+	}
+
+	_, err := b.ApplyNodeMutations(&model.NodeMutations{Add: []model.NodeTemplate{
+		{Node: template, Count: 2},
+		{Node: &v1.Node{ObjectMeta: v1.ObjectMeta{Name: "single"}}},
+	}})
+
+	assert.NoError(t, err)
+	for _, name := range []string{"m5-xlarge-1", "m5-xlarge-2"} {
+		node, ok := b.state.GetNode(name)
+		if assert.True(t, ok, name) {
+			assert.Equal(t, "m5.xlarge", node.Labels["instance-type"])
+			assert.Equal(t, name, node.Labels["kubernetes.io/hostname"])
+			assert.Equal(t, template.Status.Capacity, node.Status.Allocatable)
+			assert.Equal(t, v1.ConditionTrue, node.Status.Conditions[0].Status)
+		}
+	}
+	_, ok := b.state.GetNode("single")
+	assert.True(t, ok)
+	assert.Empty(t, template.Labels["kubernetes.io/hostname"])
+}
+
+func TestTooManyNodesFromTemplateRejected(t *testing.T) {
+	b := newNodesTestBrain(t, nil, nil)
+
+	_, err := b.ApplyNodeMutations(&model.NodeMutations{Add: []model.NodeTemplate{
+		{Node: &v1.Node{ObjectMeta: v1.ObjectMeta{Name: "node"}}, Count: model.MaxTemplateNodes + 1},
+	}})
+
+	assert.EqualError(t, err, "error adding nodes: count 1001 of template node is greater than 1000")
+	assert.Empty(t, b.GetNodes().Items)
+}
@@ -0,0 +1,111 @@
+package brain
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Scheduler preempts pods by nominating a node for the preemptor and then deleting the victims on that node,
+// so deleted pods are attributed to the pod nominated most recently.
+type preemptions struct {
+	mutex     sync.Mutex
+	preemptor string
+	// Map pod key to the node nominated for the pod
+	nominatedNodes map[string]string
+	// Map pod key of the preemptor to keys of pods it preempted
+	victims map[string][]string
+}
+
+func newPreemptions() *preemptions {
+	return &preemptions{
+		nominatedNodes: make(map[string]string),
+		victims:        make(map[string][]string),
+	}
+}
+
+// Forgets preemptions of previous simulations
+func (b *Brain) ResetPreemptions() {
+	b.preemptions.mutex.Lock()
+	defer b.preemptions.mutex.Unlock()
+
+	b.preemptions.preemptor = ""
+	b.preemptions.nominatedNodes = make(map[string]string)
+	b.preemptions.victims = make(map[string][]string)
+}
+
+// Records the node nominated for the pod by the scheduler, pods deleted afterwards are victims of this pod.
+func (b *Brain) NominatePod(namespace, podName, nodeName string) {
+	b.preemptions.mutex.Lock()
+	defer b.preemptions.mutex.Unlock()
+
+	podKey := model.PodKey(namespace, podName)
+	b.preemptions.nominatedNodes[podKey] = nodeName
+	b.preemptions.preemptor = podKey
+}
+
+// Removes the pod deleted or evicted by the scheduler from the state and records it as a victim of the preemptor.
+func (b *Brain) EvictPod(namespace, podName string) error {
+	if err := b.RemovePodFromState(namespace, podName); err != nil {
+		return err
+	}
+
+	b.preemptions.mutex.Lock()
+	defer b.preemptions.mutex.Unlock()
+
+	podKey := model.PodKey(namespace, podName)
+	if b.preemptions.preemptor == "" {
+		log.Printf("Pod %s deleted by the scheduler without a nominated preemptor", podKey)
+		return nil
+	}
+	b.preemptions.victims[b.preemptions.preemptor] = append(b.preemptions.victims[b.preemptions.preemptor], podKey)
+
+	return nil
+}
+
+// Returns the node nominated for the pod and the pods it preempted
+func (b *Brain) Preemptions(podKey string) (string, []string) {
+	b.preemptions.mutex.Lock()
+	defer b.preemptions.mutex.Unlock()
+
+	return b.preemptions.nominatedNodes[podKey], append([]string(nil), b.preemptions.victims[podKey]...)
+}
+
+func (b *Brain) GetPriorityClasses() []model.PriorityClass {
+	return b.state.GetPriorityClasses()
+}
+
+// Resolves the priority of a new pod the way the priority admission plugin does: from its priority class,
+// or from the global default class if the pod has none. Priorities set explicitly are kept.
+func (b *Brain) resolvePriority(pod *v1.Pod) error {
+	if model.PodPriority(pod) != nil {
+		return nil
+	}
+
+	className := pod.Annotations[model.PriorityClassNameAnnotationKey]
+	for _, class := range b.state.GetPriorityClasses() {
+		if class.Name == className || (className == "" && class.GlobalDefault) {
+			setPriority(pod, class.Value)
+			return nil
+		}
+	}
+
+	if className != "" {
+		return fmt.Errorf("no PriorityClass with name %s found", className)
+	}
+
+	return nil
+}
+
+func setPriority(pod *v1.Pod, priority int32) {
+	annotations := map[string]string{model.PriorityAnnotationKey: strconv.FormatInt(int64(priority), 10)}
+	for key, value := range pod.Annotations {
+		annotations[key] = value
+	}
+	pod.Annotations = annotations
+}
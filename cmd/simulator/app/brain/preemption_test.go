@@ -0,0 +1,89 @@
+package brain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/kubeClient"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+func newTestBrain(t *testing.T, pods []v1.Pod, priorityClasses []model.PriorityClass) *Brain {
+	clusterState, err := state.InitState(newSnapshotFetcher(pods, priorityClasses), nil)
+	assert.NoError(t, err)
+
+	return New(clusterState, make(chan *v1.Event, 10), 1)
+}
+
+func newSnapshotFetcher(pods []v1.Pod, priorityClasses []model.PriorityClass) kubeClient.ClusterStateFetcher {
+	return kubeClient.NewSnapshotFetcher(&model.Snapshot{Pods: pods, PriorityClasses: priorityClasses})
+}
+
+func newPriorityClass(name string, value int32, globalDefault bool) model.PriorityClass {
+	return model.PriorityClass{ObjectMeta: v1.ObjectMeta{Name: name}, Value: value, GlobalDefault: globalDefault}
+}
+
+func newAnnotatedPod(name string, annotations map[string]string) v1.Pod {
+	return v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: v1.NamespaceDefault, Name: name, Annotations: annotations}}
+}
+
+func TestPriorityResolvedFromPriorityClass(t *testing.T) {
+	b := newTestBrain(t, nil, []model.PriorityClass{
+		newPriorityClass("high", 1000, false),
+		newPriorityClass("normal", 100, true),
+	})
+
+	assert.NoError(t, b.AddPodToState(newAnnotatedPod("high", map[string]string{
+		model.PriorityClassNameAnnotationKey: "high",
+	})))
+	assert.NoError(t, b.AddPodToState(newAnnotatedPod("default", nil)))
+	assert.NoError(t, b.AddPodToState(newAnnotatedPod("explicit", map[string]string{
+		model.PriorityClassNameAnnotationKey: "high",
+		model.PriorityAnnotationKey:          "5",
+	})))
+	assert.Error(t, b.AddPodToState(newAnnotatedPod("unknown", map[string]string{
+		model.PriorityClassNameAnnotationKey: "unknown",
+	})))
+
+	for name, priority := range map[string]int32{"high": 1000, "default": 100, "explicit": 5} {
+		pod, err := b.GetPod(v1.NamespaceDefault, name)
+		if assert.NoError(t, err) && assert.NotNil(t, model.PodPriority(pod), name) {
+			assert.Equal(t, priority, *model.PodPriority(pod), name)
+		}
+	}
+}
+
+func TestEvictedPodsAttributedToNominatedPod(t *testing.T) {
+	b := newTestBrain(t, []v1.Pod{
+		newAnnotatedPod("victim-1", nil),
+		newAnnotatedPod("victim-2", nil),
+		newAnnotatedPod("victim-3", nil),
+	}, nil)
+
+	b.NominatePod(v1.NamespaceDefault, "first", "node-a")
+	assert.NoError(t, b.EvictPod(v1.NamespaceDefault, "victim-1"))
+	assert.NoError(t, b.EvictPod(v1.NamespaceDefault, "victim-2"))
+	b.NominatePod(v1.NamespaceDefault, "second", "node-b")
+	assert.NoError(t, b.EvictPod(v1.NamespaceDefault, "victim-3"))
+	assert.Error(t, b.EvictPod(v1.NamespaceDefault, "victim-3"))
+
+	nominatedNode, victims := b.Preemptions("default/first")
+	assert.Equal(t, "node-a", nominatedNode)
+	assert.Equal(t, []string{"default/victim-1", "default/victim-2"}, victims)
+
+	nominatedNode, victims = b.Preemptions("default/second")
+	assert.Equal(t, "node-b", nominatedNode)
+	assert.Equal(t, []string{"default/victim-3"}, victims)
+
+	_, err := b.GetPod(v1.NamespaceDefault, "victim-1")
+	assert.Error(t, err)
+
+	b.ResetPreemptions()
+	nominatedNode, victims = b.Preemptions("default/first")
+	assert.Empty(t, nominatedNode)
+	assert.Empty(t, victims)
+}
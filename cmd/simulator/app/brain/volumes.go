@@ -0,0 +1,332 @@
+package brain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/resource"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/labels"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Parameters of in-tree provisioners choosing the zone of provisioned volumes
+const (
+	zoneParameter  = "zone"
+	zonesParameter = "zones"
+)
+
+// Provisioners whose volumes are counted by the scheduler, provisioned PVs get their volume source
+const (
+	gcePDProvisioner  = "kubernetes.io/gce-pd"
+	awsEBSProvisioner = "kubernetes.io/aws-ebs"
+)
+
+func (b *Brain) GetStorageClasses() []model.StorageClass {
+	return b.state.GetStorageClasses()
+}
+
+// Adds PVCs to the state and binds them the way the PV controller would: PVCs without a storage class get
+// the default class, PVCs are bound to the smallest available PV of their class that satisfies them,
+// otherwise a new PV is provisioned by their class. PVCs that cannot be bound stay Pending, so pods using them
+// fail scheduling. Volumes are bound immediately, even for classes that wait for the first consumer.
+// PVCs that already exist are reused as they are, like StatefulSet controller reuses claims of its pods.
+func (b *Brain) BindClaims(claims []*v1.PersistentVolumeClaim) ([]model.ClaimResult, error) {
+	results := make([]model.ClaimResult, 0, len(claims))
+
+	for _, claim := range claims {
+		pvc := *claim
+		if pvc.Namespace == "" {
+			pvc.Namespace = v1.NamespaceDefault
+		}
+		if pvc.Name == "" {
+			return nil, fmt.Errorf("error adding persistent volume claim: claim in namespace %s has no name",
+				pvc.Namespace)
+		}
+		if existing := b.findClaim(pvc.Namespace, pvc.Name); existing != nil {
+			results = append(results, existingClaimResult(existing))
+			continue
+		}
+
+		pvc.CreationTimestamp = unversioned.Now()
+		pvc.Status = v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending}
+		b.setDefaultStorageClass(&pvc)
+
+		result, err := b.bindClaim(&pvc)
+		if err != nil {
+			return nil, err
+		}
+		b.state.AddPvc(pvc)
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+func (b *Brain) bindClaim(pvc *v1.PersistentVolumeClaim) (*model.ClaimResult, error) {
+	className, _ := model.StorageClassName(&pvc.ObjectMeta)
+	result := &model.ClaimResult{
+		ClaimName:    model.PodKey(pvc.Namespace, pvc.Name),
+		Result:       model.ClaimPendingResult,
+		StorageClass: className,
+	}
+
+	pv, message := b.findVolume(pvc, className)
+	if pv != nil {
+		bindVolume(pv, pvc)
+		b.state.UpdatePv(*pv)
+
+		result.Result = model.ClaimBoundResult
+		result.VolumeName = pv.Name
+		return result, nil
+	}
+	if pvc.Spec.VolumeName != "" {
+		result.Message = message
+		return result, nil
+	}
+
+	class, message := b.provisioningClass(className)
+	if class == nil {
+		result.Message = message
+		return result, nil
+	}
+
+	provisioned := newProvisionedVolume(pvc, class)
+	bindVolume(&provisioned, pvc)
+	if !b.state.AddPv(provisioned) {
+		return nil, fmt.Errorf("error provisioning volume for claim %s: volume %s already exists",
+			result.ClaimName, provisioned.Name)
+	}
+
+	result.Result = model.ClaimProvisionedResult
+	result.VolumeName = provisioned.Name
+	return result, nil
+}
+
+// Returns the PV the claim can be bound to, or the reason why there is none.
+func (b *Brain) findVolume(pvc *v1.PersistentVolumeClaim, className string) (*v1.PersistentVolume, string) {
+	// Items are shared with the state, so they are copied before sorting and binding
+	pvs := append([]v1.PersistentVolume(nil), b.state.GetPvs().Items...)
+	sort.Sort(volumesByName(pvs))
+
+	if pvc.Spec.VolumeName != "" {
+		for i := range pvs {
+			if pvs[i].Name != pvc.Spec.VolumeName {
+				continue
+			}
+			if !isAvailableFor(&pvs[i], pvc) {
+				return nil, fmt.Sprintf("persistent volume %s is bound to another claim", pvc.Spec.VolumeName)
+			}
+			return &pvs[i], ""
+		}
+		return nil, fmt.Sprintf("persistent volume %s not found", pvc.Spec.VolumeName)
+	}
+
+	selector := labels.Everything()
+	if pvc.Spec.Selector != nil {
+		var err error
+		if selector, err = unversioned.LabelSelectorAsSelector(pvc.Spec.Selector); err != nil {
+			return nil, fmt.Sprintf("invalid selector: %s", err)
+		}
+	}
+
+	var best *v1.PersistentVolume
+	for i := range pvs {
+		pv := &pvs[i]
+		if pvClassName, _ := model.StorageClassName(&pv.ObjectMeta); pvClassName != className {
+			continue
+		}
+		if !isAvailableFor(pv, pvc) || !selector.Matches(labels.Set(pv.Labels)) || !satisfies(pv, pvc) {
+			continue
+		}
+
+		if size := volumeSize(pv); best == nil || size.Cmp(volumeSize(best)) < 0 {
+			best = pv
+		}
+	}
+
+	if best == nil {
+		return nil, "no persistent volume matches the claim"
+	}
+
+	return best, ""
+}
+
+// Returns the class that provisions a volume for the claim, or the reason why there is none.
+func (b *Brain) provisioningClass(className string) (*model.StorageClass, string) {
+	if className == "" {
+		return nil, "no persistent volume matches the claim and the claim has no storage class"
+	}
+
+	for _, class := range b.state.GetStorageClasses() {
+		if class.Name != className {
+			continue
+		}
+		if class.Provisioner == "" || class.Provisioner == model.NoProvisioner {
+			return nil, fmt.Sprintf("no persistent volume matches the claim and storage class %s "+
+				"does not provision volumes", className)
+		}
+		return &class, ""
+	}
+
+	return nil, fmt.Sprintf("no persistent volume matches the claim and storage class %s not found", className)
+}
+
+// PVCs without a storage class get the default class when they are created
+func (b *Brain) setDefaultStorageClass(pvc *v1.PersistentVolumeClaim) {
+	if _, ok := model.StorageClassName(&pvc.ObjectMeta); ok {
+		return
+	}
+
+	classes := b.state.GetStorageClasses()
+	sort.Sort(storageClassesByName(classes))
+	for _, class := range classes {
+		if class.IsDefault() {
+			pvc.Annotations = withAnnotation(pvc.Annotations, model.StorageClassAnnotationKey, class.Name)
+			return
+		}
+	}
+}
+
+func (b *Brain) findClaim(namespace, name string) *v1.PersistentVolumeClaim {
+	for _, pvc := range b.state.GetPvcs().Items {
+		if pvc.Namespace == namespace && pvc.Name == name {
+			return &pvc
+		}
+	}
+
+	return nil
+}
+
+func existingClaimResult(pvc *v1.PersistentVolumeClaim) model.ClaimResult {
+	className, _ := model.StorageClassName(&pvc.ObjectMeta)
+	result := model.ClaimResult{
+		ClaimName:    model.PodKey(pvc.Namespace, pvc.Name),
+		Result:       model.ClaimPendingResult,
+		StorageClass: className,
+		Message:      "existing claim reused, it is not bound to a volume",
+	}
+	if pvc.Spec.VolumeName != "" {
+		result.Result = model.ClaimBoundResult
+		result.VolumeName = pvc.Spec.VolumeName
+		result.Message = "existing claim reused"
+	}
+
+	return result
+}
+
+// PV is available if it is not bound, or if it is reserved for the claim
+func isAvailableFor(pv *v1.PersistentVolume, pvc *v1.PersistentVolumeClaim) bool {
+	if pv.Spec.ClaimRef == nil {
+		return pv.Status.Phase != v1.VolumeReleased && pv.Status.Phase != v1.VolumeFailed
+	}
+
+	return pv.Spec.ClaimRef.Namespace == pvc.Namespace && pv.Spec.ClaimRef.Name == pvc.Name
+}
+
+// PV satisfies the claim if it has all access modes and at least the storage requested by the claim
+func satisfies(pv *v1.PersistentVolume, pvc *v1.PersistentVolumeClaim) bool {
+	for _, mode := range pvc.Spec.AccessModes {
+		found := false
+		for _, pvMode := range pv.Spec.AccessModes {
+			found = found || pvMode == mode
+		}
+		if !found {
+			return false
+		}
+	}
+
+	request := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	size := volumeSize(pv)
+	return size.Cmp(request) >= 0
+}
+
+func volumeSize(pv *v1.PersistentVolume) resource.Quantity {
+	return pv.Spec.Capacity[v1.ResourceStorage]
+}
+
+func bindVolume(pv *v1.PersistentVolume, pvc *v1.PersistentVolumeClaim) {
+	pv.Spec.ClaimRef = &v1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  pvc.Namespace,
+		Name:       pvc.Name,
+		UID:        pvc.UID,
+	}
+	pv.Status.Phase = v1.VolumeBound
+
+	pvc.Spec.VolumeName = pv.Name
+	pvc.Status = v1.PersistentVolumeClaimStatus{
+		Phase:       v1.ClaimBound,
+		AccessModes: pv.Spec.AccessModes,
+		Capacity:    pv.Spec.Capacity,
+	}
+}
+
+// Provisioned PVs have the size and access modes requested by the claim. PVs of GCE and AWS provisioners
+// are placed in the zone given by the class parameters, otherwise they are not constrained to a zone.
+func newProvisionedVolume(pvc *v1.PersistentVolumeClaim, class *model.StorageClass) v1.PersistentVolume {
+	name := fmt.Sprintf("pvc-%s-%s", pvc.Namespace, pvc.Name)
+
+	pv := v1.PersistentVolume{
+		ObjectMeta: v1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				model.StorageClassAnnotationKey:   class.Name,
+				"pv.kubernetes.io/provisioned-by": class.Provisioner,
+			},
+			CreationTimestamp: unversioned.Now(),
+		},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:                      v1.ResourceList{v1.ResourceStorage: pvc.Spec.Resources.Requests[v1.ResourceStorage]},
+			AccessModes:                   pvc.Spec.AccessModes,
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+		},
+	}
+
+	switch class.Provisioner {
+	case gcePDProvisioner:
+		pv.Spec.GCEPersistentDisk = &v1.GCEPersistentDiskVolumeSource{PDName: name}
+	case awsEBSProvisioner:
+		pv.Spec.AWSElasticBlockStore = &v1.AWSElasticBlockStoreVolumeSource{VolumeID: name}
+	default:
+		return pv
+	}
+
+	zone := class.Parameters[zoneParameter]
+	if zones := strings.Split(class.Parameters[zonesParameter], ","); zone == "" && len(zones) == 1 {
+		zone = strings.TrimSpace(zones[0])
+	}
+	if zone != "" {
+		pv.Labels = map[string]string{unversioned.LabelZoneFailureDomain: zone}
+	}
+
+	return pv
+}
+
+// Annotations map is copied, because objects created from the same template share it
+func withAnnotation(annotations map[string]string, key, value string) map[string]string {
+	result := map[string]string{key: value}
+	for k, v := range annotations {
+		if k != key {
+			result[k] = v
+		}
+	}
+
+	return result
+}
+
+type volumesByName []v1.PersistentVolume
+
+func (v volumesByName) Len() int           { return len(v) }
+func (v volumesByName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v volumesByName) Less(i, j int) bool { return v[i].Name < v[j].Name }
+
+type storageClassesByName []model.StorageClass
+
+func (c storageClassesByName) Len() int           { return len(c) }
+func (c storageClassesByName) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c storageClassesByName) Less(i, j int) bool { return c[i].Name < c[j].Name }
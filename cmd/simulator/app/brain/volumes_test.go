@@ -0,0 +1,120 @@
+package brain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/resource"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	mocks "github.com/Prytu/risk-advisor/cmd/simulator/app/mock"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/kubeClient"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+func newVolumesTestBrain(t *testing.T, pvs []v1.PersistentVolume, classes []model.StorageClass) *Brain {
+	fetcher := kubeClient.NewSnapshotFetcher(&model.Snapshot{PersistentVolumes: pvs, StorageClasses: classes})
+	clusterState, err := state.InitState(fetcher, nil)
+	assert.NoError(t, err)
+
+	return New(clusterState, make(chan *v1.Event, 10), 1)
+}
+
+func newVolume(name, storage string) v1.PersistentVolume {
+	return v1.PersistentVolume{
+		ObjectMeta: v1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:    v1.ResourceList{v1.ResourceStorage: resource.MustParse(storage)},
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+		},
+	}
+}
+
+func TestClaimIsBoundToSmallestMatchingVolume(t *testing.T) {
+	b := newVolumesTestBrain(t, []v1.PersistentVolume{
+		newVolume("large", "100Gi"), newVolume("small", "1Gi"), newVolume("medium", "10Gi"),
+	}, nil)
+
+	results, err := b.BindClaims([]*v1.PersistentVolumeClaim{mocks.NewClaim("data", "5Gi")})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ClaimResult{
+		{ClaimName: "default/data", Result: model.ClaimBoundResult, VolumeName: "medium"},
+	}, results)
+	for _, pv := range b.GetPvs().Items {
+		if pv.Name == "medium" {
+			assert.Equal(t, v1.VolumeBound, pv.Status.Phase)
+			assert.Equal(t, "data", pv.Spec.ClaimRef.Name)
+		} else {
+			assert.Nil(t, pv.Spec.ClaimRef)
+		}
+	}
+	if assert.Len(t, b.GetPvcs().Items, 1) {
+		assert.Equal(t, v1.ClaimBound, b.GetPvcs().Items[0].Status.Phase)
+	}
+}
+
+func TestClaimWithoutVolumeIsProvisionedByDefaultClass(t *testing.T) {
+	b := newVolumesTestBrain(t, nil, []model.StorageClass{
+		{ObjectMeta: v1.ObjectMeta{Name: "slow"}, Provisioner: gcePDProvisioner},
+		{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "standard",
+				Annotations: map[string]string{model.DefaultStorageClassAnnotationKey: "true"},
+			},
+			Provisioner: gcePDProvisioner,
+			Parameters:  map[string]string{zoneParameter: "us-central1-a"},
+		},
+	})
+
+	results, err := b.BindClaims([]*v1.PersistentVolumeClaim{mocks.NewClaim("data", "5Gi")})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ClaimResult{{
+		ClaimName:    "default/data",
+		Result:       model.ClaimProvisionedResult,
+		VolumeName:   "pvc-default-data",
+		StorageClass: "standard",
+	}}, results)
+	if assert.Len(t, b.GetPvs().Items, 1) {
+		pv := b.GetPvs().Items[0]
+		assert.Equal(t, "us-central1-a", pv.Labels[unversioned.LabelZoneFailureDomain])
+		assert.Equal(t, "pvc-default-data", pv.Spec.GCEPersistentDisk.PDName)
+	}
+}
+
+func TestClaimWithoutClassStaysPending(t *testing.T) {
+	b := newVolumesTestBrain(t, []v1.PersistentVolume{newVolume("small", "1Gi")}, nil)
+
+	results, err := b.BindClaims([]*v1.PersistentVolumeClaim{mocks.NewClaim("data", "5Gi")})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ClaimResult{{
+		ClaimName: "default/data",
+		Result:    model.ClaimPendingResult,
+		Message:   "no persistent volume matches the claim and the claim has no storage class",
+	}}, results)
+	if assert.Len(t, b.GetPvcs().Items, 1) {
+		assert.Equal(t, v1.ClaimPending, b.GetPvcs().Items[0].Status.Phase)
+	}
+}
+
+func TestExistingClaimIsReused(t *testing.T) {
+	b := newVolumesTestBrain(t, []v1.PersistentVolume{newVolume("small", "1Gi"), newVolume("large", "10Gi")}, nil)
+	_, err := b.BindClaims([]*v1.PersistentVolumeClaim{mocks.NewClaim("data-db-0", "5Gi")})
+	assert.NoError(t, err)
+
+	results, err := b.BindClaims([]*v1.PersistentVolumeClaim{mocks.NewClaim("data-db-0", "1Gi")})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ClaimResult{{
+		ClaimName:  "default/data-db-0",
+		Result:     model.ClaimBoundResult,
+		VolumeName: "large",
+		Message:    "existing claim reused",
+	}}, results)
+	assert.Len(t, b.GetPvcs().Items, 1)
+}
@@ -2,6 +2,7 @@ package initializer
 
 import (
 	"fmt"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"k8s.io/client-go/1.5/pkg/api/v1"
@@ -14,20 +15,29 @@ import (
 	"github.com/Prytu/risk-advisor/pkg/kubeClient"
 )
 
-// Returns HTTPHandlerFunc that will handle requests from riskadvisor.
-// On initialization error it will return a function that responds with error message that will describe that error.
+// Returns HTTPHandlerFuncs that will handle advice and resilience requests from riskadvisor.
+// On initialization error it will return functions that respond with error message that will describe that error.
+// Namespaced objects are fetched from the given namespaces only, or from all of them if none are given.
+// Long-lived simulator fetches the cluster state again before each request, otherwise the state fetched during
+// initialization is used for the only simulation. Simulation timeout is used for requests that do not set their own.
+// Scheduler names are names of all schedulers talking to the simulator.
 func Initialize(
 	schedulerCommunicationPort string,
 	initStateFunc state.InitStateFunc,
-	ksf kubeClient.ClusterCommunicator,
-) riskadvisorhandler.HTTPHandlerFunc {
+	ksf kubeClient.ClusterStateFetcher,
+	namespaces []string,
+	longLived bool,
+	simulationTimeout time.Duration,
+	schedulerNames []string,
+) (riskadvisorhandler.HTTPHandlerFunc, riskadvisorhandler.HTTPHandlerFunc) {
 	// get state from apiserver
-	clusterState, err := initStateFunc(ksf)
+	clusterState, err := initStateFunc(ksf, namespaces)
 	if err != nil {
 		errorMsg := "failed to fetch cluster state"
 		log.WithError(err).Error(errorMsg)
 
-		return riskadvisorhandler.ErrorResponseHandler(fmt.Errorf("%s (%s)", errorMsg, err))
+		errorHandler := riskadvisorhandler.ErrorResponseHandler(fmt.Errorf("%s (%s)", errorMsg, err))
+		return errorHandler, errorHandler
 	}
 
 	// Channel for sending scheduling results between brain and simulator
@@ -35,11 +45,17 @@ func Initialize(
 	// Channel for simulation errors
 	errorChannel := make(chan error)
 
-	b := brain.New(clusterState, eventChannel)
+	b := brain.New(clusterState, eventChannel, len(schedulerNames))
 	sh := schedulerHandler.New(b, schedulerCommunicationPort, errorChannel)
 
-	s := simulator.New(b, sh, eventChannel, errorChannel)
+	s := simulator.New(b, sh, eventChannel, errorChannel, schedulerNames)
+	if longLived {
+		s = simulator.NewRefreshing(s, b, func() (*state.ClusterState, error) {
+			return initStateFunc(ksf, namespaces)
+		})
+	}
 
-	// Handler for risk-advisor requests (advise)
-	return riskadvisorhandler.MultiplePodAdviseHandler(s)
+	// Handlers for risk-advisor requests (advise and resilience)
+	return riskadvisorhandler.MultiplePodAdviseHandler(s, simulationTimeout),
+		riskadvisorhandler.ResilienceHandler(s, simulationTimeout)
 }
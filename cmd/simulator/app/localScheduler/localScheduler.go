@@ -0,0 +1,35 @@
+package localscheduler
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Starts kube-scheduler binary as a child process talking to the simulator on the given port,
+// the same way as the scheduler container of simulator pod does. Additional arguments are passed to the scheduler.
+// Simulator cannot simulate anything without the scheduler, so it exits when the scheduler does.
+func Start(binary, schedulerCommunicationPort string, args []string) (*exec.Cmd, error) {
+	schedulerArgs := append([]string{
+		fmt.Sprintf("--master=127.0.0.1:%s", schedulerCommunicationPort),
+		"--leader-elect=false",
+		"--kube-api-content-type=application/json",
+	}, args...)
+
+	cmd := exec.Command(binary, schedulerArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting scheduler %s: %s", binary, err)
+	}
+
+	go func() {
+		err := cmd.Wait()
+		log.WithError(err).Fatalf("Scheduler %s exited", binary)
+	}()
+
+	return cmd, nil
+}
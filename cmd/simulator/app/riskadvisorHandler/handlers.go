@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 
@@ -15,7 +16,8 @@ import (
 
 type HTTPHandlerFunc func(w http.ResponseWriter, r *http.Request)
 
-func MultiplePodAdviseHandler(s simulator.SimulationRunner) HTTPHandlerFunc {
+// Simulations that do not set their timeout are stopped after the default timeout.
+func MultiplePodAdviseHandler(s simulator.SimulationRunner, defaultTimeout time.Duration) HTTPHandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		clusterMutations, err := parseAdviseRequestBody(r.Body)
 		if err != nil {
@@ -25,7 +27,13 @@ func MultiplePodAdviseHandler(s simulator.SimulationRunner) HTTPHandlerFunc {
 			return
 		}
 
-		result, err := s.RunMultiplePodSimulation(clusterMutations.ToCreate, clusterMutations.ToDelete)
+		timeout := defaultTimeout
+		if clusterMutations.TimeoutSeconds > 0 {
+			timeout = time.Duration(clusterMutations.TimeoutSeconds) * time.Second
+		}
+
+		response, err := s.RunMultiplePodSimulation(clusterMutations.ToCreate, clusterMutations.ToDelete,
+			clusterMutations.PersistentVolumeClaims, clusterMutations.Nodes, clusterMutations.Autoscale, timeout)
 		if err != nil {
 			errorMsg := "simulation error"
 			log.WithError(err).Error(errorMsg)
@@ -33,6 +41,17 @@ func MultiplePodAdviseHandler(s simulator.SimulationRunner) HTTPHandlerFunc {
 			return
 		}
 
+		// Only the table of results is returned, unless the capacity projection or the snapshot was requested,
+		// or nodes were changed (which may need to reschedule existing pods) or estimated, or PVCs were bound
+		if !clusterMutations.Snapshot {
+			response.Snapshot = nil
+		}
+		var result interface{} = response.Results
+		if clusterMutations.Capacity || clusterMutations.Snapshot || !clusterMutations.Nodes.IsEmpty() ||
+			len(clusterMutations.Autoscale) > 0 || len(clusterMutations.PersistentVolumeClaims) > 0 {
+			result = response
+		}
+
 		resultJSON, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
 			errorMsg := "error marshalling response"
@@ -46,6 +65,44 @@ func MultiplePodAdviseHandler(s simulator.SimulationRunner) HTTPHandlerFunc {
 	}
 }
 
+// Analyses failures of every node, or of every group of nodes with the same value of the domain label.
+// Analyses that do not set their timeout are stopped after the default timeout.
+func ResilienceHandler(s simulator.SimulationRunner, defaultTimeout time.Duration) HTTPHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request model.ResilienceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+			errorMsg := "invalid request body"
+			log.WithError(err).Error(errorMsg)
+			respondWithError(w, fmt.Sprintf("%s (%s)", errorMsg, err), http.StatusBadRequest)
+			return
+		}
+
+		timeout := defaultTimeout
+		if request.TimeoutSeconds > 0 {
+			timeout = time.Duration(request.TimeoutSeconds) * time.Second
+		}
+
+		response, err := s.RunResilienceAnalysis(request.DomainLabel, timeout)
+		if err != nil {
+			errorMsg := "resilience analysis error"
+			log.WithError(err).Error(errorMsg)
+			respondWithError(w, fmt.Sprintf("%s (%s)", errorMsg, err), http.StatusInternalServerError)
+			return
+		}
+
+		resultJSON, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			errorMsg := "error marshalling response"
+			log.WithError(err).Error(errorMsg)
+			respondWithError(w, fmt.Sprintf("%s (%s)", errorMsg, err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resultJSON)
+	}
+}
+
 func ErrorResponseHandler(err error) HTTPHandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		errorMessage := fmt.Sprintf("Error during simulator initalization: %s.", err)
@@ -66,6 +123,17 @@ func parseAdviseRequestBody(requestBody io.ReadCloser) (*model.SimulatorRequest,
 		return nil, fmt.Errorf("error unmarshalling body: %s", err)
 	}
 
+	templates := append([]model.NodeTemplate(nil), adviceRequest.Autoscale...)
+	if adviceRequest.Nodes != nil {
+		templates = append(templates, adviceRequest.Nodes.Add...)
+	}
+	for _, template := range templates {
+		if template.Count > model.MaxTemplateNodes {
+			return nil, fmt.Errorf("count %d of node template %s is greater than %d",
+				template.Count, template.Name(), model.MaxTemplateNodes)
+		}
+	}
+
 	return &adviceRequest, nil
 }
 
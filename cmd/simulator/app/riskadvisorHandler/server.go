@@ -11,10 +11,11 @@ type RiskAdvisorHandler struct {
 	server *mux.Router
 }
 
-func New(adviseHandler HTTPHandlerFunc) *RiskAdvisorHandler {
+func New(adviseHandler, resilienceHandler HTTPHandlerFunc) *RiskAdvisorHandler {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/advise", adviseHandler).Methods("POST")
+	r.HandleFunc("/resilience", resilienceHandler).Methods("POST")
 	r.HandleFunc("/alive", aliveHandler).Methods("GET")
 
 	return &RiskAdvisorHandler{
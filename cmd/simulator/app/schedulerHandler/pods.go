@@ -0,0 +1,186 @@
+package schedulerHandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/gorilla/mux.v1"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Schedulers of version 1.8 keep the node nominated for a preempting pod in this annotation,
+// newer versions in status.nominatedNodeName
+const nominatedNodeAnnotationKey = "NominatedNodeName"
+
+// PodList with items encoded by encodePod
+type podList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+	Items                []json.RawMessage `json:"items"`
+}
+
+// Encodes the pod with fields kept in its annotations restored in its spec,
+// so that newer schedulers see its scheduler name and priority.
+func encodePod(pod *v1.Pod) (json.RawMessage, error) {
+	data, err := json.Marshal(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := model.PodDroppedSpecFields(pod)
+	if fields.SchedulerName == "" && fields.PriorityClassName == "" && fields.Priority == nil {
+		return data, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	var spec map[string]json.RawMessage
+	if err := json.Unmarshal(object["spec"], &spec); err != nil {
+		return nil, err
+	}
+
+	if fields.SchedulerName != "" {
+		spec["schedulerName"], _ = json.Marshal(fields.SchedulerName)
+	}
+	if fields.PriorityClassName != "" {
+		spec["priorityClassName"], _ = json.Marshal(fields.PriorityClassName)
+	}
+	if fields.Priority != nil {
+		spec["priority"] = json.RawMessage(strconv.FormatInt(int64(*fields.Priority), 10))
+	}
+
+	if object["spec"], err = json.Marshal(spec); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(object)
+}
+
+func encodePodList(pods *v1.PodList) (*podList, error) {
+	list := &podList{
+		TypeMeta: pods.TypeMeta,
+		ListMeta: pods.ListMeta,
+		Items:    make([]json.RawMessage, len(pods.Items)),
+	}
+
+	for i := range pods.Items {
+		item, err := encodePod(&pods.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		list.Items[i] = item
+	}
+
+	return list, nil
+}
+
+// Scheduler deletes pods it preempts
+func (sh *SchedulerHandler) deletePod(w http.ResponseWriter, r *http.Request) {
+	sh.evict(w, r, http.StatusOK)
+}
+
+// Pods can also be preempted through the eviction subresource
+func (sh *SchedulerHandler) evictPod(w http.ResponseWriter, r *http.Request) {
+	sh.evict(w, r, http.StatusCreated)
+}
+
+func (sh *SchedulerHandler) evict(w http.ResponseWriter, r *http.Request, successCode int) {
+	vars := mux.Vars(r)
+
+	if err := sh.brain.EvictPod(vars["namespace"], vars["podname"]); err != nil {
+		writeStatus(w, http.StatusNotFound, unversioned.StatusReasonNotFound, err.Error())
+		return
+	}
+
+	writeStatus(w, successCode, "", "")
+}
+
+// Schedulers 1.9+ nominate the node for a preempting pod by updating its status
+func (sh *SchedulerHandler) updatePodStatus(w http.ResponseWriter, r *http.Request) {
+	var update struct {
+		Status struct {
+			NominatedNodeName string `json:"nominatedNodeName"`
+		} `json:"status"`
+	}
+
+	sh.updatePodWith(w, r, &update, func() string {
+		return update.Status.NominatedNodeName
+	})
+}
+
+// Schedulers 1.8 nominate the node for a preempting pod by updating its annotations
+func (sh *SchedulerHandler) updatePod(w http.ResponseWriter, r *http.Request) {
+	var update struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+
+	sh.updatePodWith(w, r, &update, func() string {
+		return update.Metadata.Annotations[nominatedNodeAnnotationKey]
+	})
+}
+
+// Reads the update into the given object, records the node returned by nominatedNode (if any) and responds
+// with the pod. Other changes of pods are not applied to the state, removed nominations are not tracked either,
+// because pods served to the scheduler never contain them.
+func (sh *SchedulerHandler) updatePodWith(w http.ResponseWriter, r *http.Request, update interface{},
+	nominatedNode func() string) {
+	vars := mux.Vars(r)
+	namespace, podName := vars["namespace"], vars["podname"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sh.handleError(fmt.Errorf("error reading from request body in updatePod handler: %v", err))
+		return
+	}
+
+	if err := json.Unmarshal(body, update); err != nil {
+		writeStatus(w, http.StatusBadRequest, unversioned.StatusReasonBadRequest, err.Error())
+		return
+	}
+
+	pod, err := sh.brain.GetPod(namespace, podName)
+	if err != nil {
+		writeStatus(w, http.StatusNotFound, unversioned.StatusReasonNotFound, err.Error())
+		return
+	}
+
+	if nodeName := nominatedNode(); nodeName != "" {
+		sh.brain.NominatePod(namespace, podName, nodeName)
+	}
+
+	podJSON, err := encodePod(pod)
+	if err != nil {
+		sh.handleError(marshallingError("updatePod", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(podJSON)
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason unversioned.StatusReason, message string) {
+	status := unversioned.Status{
+		TypeMeta: unversioned.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   unversioned.StatusSuccess,
+		Reason:   reason,
+		Message:  message,
+		Code:     int32(code),
+	}
+	if code >= http.StatusBadRequest {
+		status.Status = unversioned.StatusFailure
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(&status)
+}
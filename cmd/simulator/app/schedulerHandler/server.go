@@ -6,14 +6,58 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"gopkg.in/gorilla/mux.v1"
+	"k8s.io/client-go/1.5/pkg/api/meta"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
 	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/pkg/watch"
 
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/brain"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/model"
 )
 
+// PriorityClass served with the version of the request
+type priorityClass struct {
+	unversioned.TypeMeta `json:",inline"`
+	model.PriorityClass  `json:",inline"`
+}
+
+type priorityClassList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+	Items                []priorityClass `json:"items"`
+}
+
+// StorageClass served with the version of the request
+type storageClass struct {
+	unversioned.TypeMeta `json:",inline"`
+	model.StorageClass   `json:",inline"`
+}
+
+type storageClassList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+	Items                []storageClass `json:"items"`
+}
+
+// StatefulSet or PodDisruptionBudget served with the version of the request
+type opaqueObject struct {
+	unversioned.TypeMeta `json:",inline"`
+	model.OpaqueObject   `json:",inline"`
+}
+
+type opaqueList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+	Items                []opaqueObject `json:"items"`
+}
+
 type SchedulerHandler struct {
 	server  *mux.Router
 	Port    string
@@ -33,14 +77,12 @@ func New(b *brain.Brain, port string, errChan chan<- error) *SchedulerHandler {
 
 	apiv1 := r.PathPrefix("/api/v1/").Subrouter()
 
-	apiv1.HandleFunc("/watch/{resource}", sh.watchers).Methods("GET")
-
-	apiv1.HandleFunc("/nodes", sh.getNodes).Methods("GET")
-	apiv1.HandleFunc("/pods", sh.getPods).Methods("GET")
-	apiv1.HandleFunc("/persistentvolumeclaims", sh.getPvcs).Methods("GET")
-	apiv1.HandleFunc("/persistentvolumes", sh.getPvs).Methods("GET")
-	apiv1.HandleFunc("/services", sh.getServices).Methods("GET")
-	apiv1.HandleFunc("/replicationcontrollers", sh.getServices).Methods("GET")
+	sh.handleResource(apiv1, "nodes", false, sh.getNodes)
+	sh.handleResource(apiv1, "pods", true, sh.getPods)
+	sh.handleResource(apiv1, "persistentvolumeclaims", true, sh.getPvcs)
+	sh.handleResource(apiv1, "persistentvolumes", false, sh.getPvs)
+	sh.handleResource(apiv1, "services", true, sh.getServices)
+	sh.handleResource(apiv1, "replicationcontrollers", true, sh.getReplicationControllers)
 
 	apiv1.HandleFunc("/namespaces/{namespace}/events", sh.event).Methods("POST")
 
@@ -48,54 +90,158 @@ func New(b *brain.Brain, port string, errChan chan<- error) *SchedulerHandler {
 	apiv1.HandleFunc("/namespaces/{namespace}/bindings", sh.binding).Methods("POST")
 	apiv1.HandleFunc("/namespaces/{namespace}/pods/{podname}", sh.binding).Methods("POST")
 
-	// PUT for pod update
+	// Preemption: schedulers get the pod, nominate a node for it and delete or evict its victims
+	apiv1.HandleFunc("/namespaces/{namespace}/pods/{podname}", sh.getPod).Methods("GET")
+	apiv1.HandleFunc("/namespaces/{namespace}/pods/{podname}", sh.updatePod).Methods("PUT", "PATCH")
+	apiv1.HandleFunc("/namespaces/{namespace}/pods/{podname}", sh.deletePod).Methods("DELETE")
+	apiv1.HandleFunc("/namespaces/{namespace}/pods/{podname}/status", sh.updatePodStatus).Methods("PUT", "PATCH")
+	apiv1.HandleFunc("/namespaces/{namespace}/pods/{podname}/eviction", sh.evictPod).Methods("POST")
 
 	extensions := r.PathPrefix("/apis/extensions/v1beta1/").Subrouter()
-	extensions.HandleFunc("/replicasets", sh.getReplicasets).Methods("GET")
+	sh.handleResource(extensions, "replicasets", true, sh.getReplicasets)
+
+	// Newer schedulers list ReplicaSets and StatefulSets of the apps group
+	apps := r.PathPrefix("/apis/apps/{version}/").Subrouter()
+	sh.handleResource(apps, "replicasets", true, sh.getReplicasets)
+	sh.handleResource(apps, "statefulsets", true, sh.opaqueList("StatefulSet", "apps", sh.brain.GetStatefulSets))
+
+	policy := r.PathPrefix("/apis/policy/{version}/").Subrouter()
+	sh.handleResource(policy, "poddisruptionbudgets", true,
+		sh.opaqueList("PodDisruptionBudget", "policy", sh.brain.GetPodDisruptionBudgets))
+
+	scheduling := r.PathPrefix("/apis/scheduling.k8s.io/{version}/").Subrouter()
+	sh.handleResource(scheduling, "priorityclasses", false, sh.getPriorityClasses)
+
+	storage := r.PathPrefix("/apis/storage.k8s.io/{version}/").Subrouter()
+	sh.handleResource(storage, "storageclasses", false, sh.getStorageClasses)
 
 	return sh
 }
 
+// Registers the list of the resource and its watch, requested either with the watch path prefix (clients up to 1.5)
+// or with the watch query parameter (newer clients). Namespaced resources are served also for a single namespace.
+func (sh *SchedulerHandler) handleResource(router *mux.Router, resource string, namespaced bool, list http.HandlerFunc) {
+	paths := []string{fmt.Sprintf("/{resource:%s}", resource)}
+	if namespaced {
+		paths = append(paths, fmt.Sprintf("/namespaces/{namespace}/{resource:%s}", resource))
+	}
+
+	for _, path := range paths {
+		router.HandleFunc("/watch"+path, sh.watch).Methods("GET")
+		router.HandleFunc(path, sh.watch).Methods("GET").MatcherFunc(watchRequested)
+		router.HandleFunc(path, list).Methods("GET")
+	}
+}
+
+func watchRequested(r *http.Request, _ *mux.RouteMatch) bool {
+	watch, _ := strconv.ParseBool(r.URL.Query().Get("watch"))
+	return watch
+}
+
 // TODO: Check if we can just 'return' without answering to scheduler in handlers when error happens
 func (sh *SchedulerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sh.server.ServeHTTP(w, r)
 }
 
-func (sh *SchedulerHandler) watchers(w http.ResponseWriter, r *http.Request) {
-	response := sh.brain.Watchers()
-
-	w.Write(response)
+// Single event of a watch stream, pods are encoded by encodePod
+type watchEvent struct {
+	Type   watch.EventType `json:"type"`
+	Object interface{}     `json:"object"`
 }
 
-func (sh *SchedulerHandler) getNodes(w http.ResponseWriter, r *http.Request) {
-	nodeList := sh.brain.GetNodes()
+// Streams changes of the resource as newline separated JSON events, until the client disconnects
+// or the requested timeout passes. Watches of a namespace get only events about objects of that namespace.
+func (sh *SchedulerHandler) watch(w http.ResponseWriter, r *http.Request) {
+	resource, namespace := mux.Vars(r)["resource"], mux.Vars(r)["namespace"]
+	query := r.URL.Query()
 
-	nodeListJSON, err := json.Marshal(&nodeList)
-	if err != nil {
-		sh.handleError(marshallingError("getNodes", err))
+	watcher, err := sh.brain.Watch(resource, query.Get("resourceVersion"), query.Get("fieldSelector"))
+	if err != nil && err != state.ErrResourceVersionTooOld {
+		log.WithError(err).Errorf("Invalid watch request for %s", resource)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(nodeListJSON)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	if err == state.ErrResourceVersionTooOld {
+		// Client has to list the resource again
+		encoder.Encode(watchEvent{Type: watch.Error, Object: tooOldResourceVersionStatus()})
+		return
+	}
+	defer watcher.Stop()
+
+	var timeout <-chan time.Time
+	if timeoutSeconds, err := strconv.Atoi(query.Get("timeoutSeconds")); err == nil {
+		timeout = time.After(time.Duration(timeoutSeconds) * time.Second)
+	}
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			if namespace != "" && !inNamespace(event.Object, namespace) {
+				continue
+			}
+
+			var object interface{} = event.Object
+			if pod, ok := event.Object.(*v1.Pod); ok {
+				if object, err = encodePod(pod); err != nil {
+					log.WithError(err).Errorf("Error encoding %s watch event", resource)
+					return
+				}
+			}
+
+			if err := encoder.Encode(watchEvent{Type: event.Type, Object: object}); err != nil {
+				log.WithError(err).Errorf("Error writing %s watch event", resource)
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-timeout:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (sh *SchedulerHandler) getNodes(w http.ResponseWriter, r *http.Request) {
+	sh.writeList(w, r, "getNodes", sh.brain.GetNodes())
 }
 
-// TODO: Maybe we should return 404 here?
 func (sh *SchedulerHandler) getPod(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
+	namespace, ok := vars["namespace"]
+	if !ok {
+		sh.handleError(errors.New("No `namespace` in vars in getPod."))
+		return
+	}
+
 	podName, ok := vars["podname"]
 	if !ok {
 		sh.handleError(errors.New("No `podname` in vars in getPod."))
 		return
 	}
 
-	pod, err := sh.brain.GetPod(podName)
+	pod, err := sh.brain.GetPod(namespace, podName)
 	if err != nil {
-		sh.handleError(fmt.Errorf("getPod error: %s", err))
+		writeStatus(w, http.StatusNotFound, unversioned.StatusReasonNotFound, err.Error())
+		return
 	}
 
-	podsJSON, err := json.Marshal(pod)
+	podsJSON, err := encodePod(pod)
 	if err != nil {
 		sh.handleError(marshallingError("getPod", err))
 		return
@@ -108,7 +254,17 @@ func (sh *SchedulerHandler) getPod(w http.ResponseWriter, r *http.Request) {
 func (sh *SchedulerHandler) getPods(w http.ResponseWriter, r *http.Request) {
 	fieldSelector := r.URL.Query().Get("fieldSelector")
 
-	podList := sh.brain.GetPods(fieldSelector)
+	pods := sh.brain.GetPods(fieldSelector)
+	if err := filterNamespace(pods, mux.Vars(r)["namespace"]); err != nil {
+		sh.handleError(fmt.Errorf("error filtering pods in getPods: %s", err))
+		return
+	}
+
+	podList, err := encodePodList(pods)
+	if err != nil {
+		sh.handleError(marshallingError("getPods", err))
+		return
+	}
 
 	podListJSON, err := json.Marshal(&podList)
 	if err != nil {
@@ -167,70 +323,157 @@ func (sh *SchedulerHandler) binding(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
-// TODO: Make a 'generic' function for those handlers
 func (sh *SchedulerHandler) getPvcs(w http.ResponseWriter, r *http.Request) {
-	pvcs := sh.brain.GetPvcs()
+	sh.writeList(w, r, "getPvcs", sh.brain.GetPvcs())
+}
 
-	pvcsJSON, err := json.Marshal(&pvcs)
-	if err != nil {
-		sh.handleError(marshallingError("getPvcs", err))
-		return
+func (sh *SchedulerHandler) getPvs(w http.ResponseWriter, r *http.Request) {
+	sh.writeList(w, r, "getPvs", sh.brain.GetPvs())
+}
+
+// ReplicaSets of the apps group are served with the version of the request
+func (sh *SchedulerHandler) getReplicasets(w http.ResponseWriter, r *http.Request) {
+	replicasets := sh.brain.GetReplicasets()
+	if version, ok := mux.Vars(r)["version"]; ok {
+		replicasets.TypeMeta = unversioned.TypeMeta{Kind: "ReplicaSetList", APIVersion: "apps/" + version}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(pvcsJSON)
+	sh.writeList(w, r, "getReplicasets", replicasets)
 }
 
-func (sh *SchedulerHandler) getPvs(w http.ResponseWriter, r *http.Request) {
-	pvs := sh.brain.GetPvs()
+func (sh *SchedulerHandler) getServices(w http.ResponseWriter, r *http.Request) {
+	sh.writeList(w, r, "getServices", sh.brain.GetServices())
+}
 
-	pvsJSON, err := json.Marshal(&pvs)
+func (sh *SchedulerHandler) getReplicationControllers(w http.ResponseWriter, r *http.Request) {
+	sh.writeList(w, r, "getReplicationControllers", sh.brain.GetReplicationControllers())
+}
+
+// Writes the list, keeping only objects of the namespace of namespaced list paths
+func (sh *SchedulerHandler) writeList(w http.ResponseWriter, r *http.Request, handlerName string, list runtime.Object) {
+	if err := filterNamespace(list, mux.Vars(r)["namespace"]); err != nil {
+		sh.handleError(fmt.Errorf("error filtering list in %s: %s", handlerName, err))
+		return
+	}
+
+	listJSON, err := json.Marshal(list)
 	if err != nil {
-		sh.handleError(marshallingError("getPvs", err))
+		sh.handleError(marshallingError(handlerName, err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(pvsJSON)
+	w.Write(listJSON)
 }
 
-func (sh *SchedulerHandler) getReplicasets(w http.ResponseWriter, r *http.Request) {
-	replicasets := sh.brain.GetReplicasets()
+// Serves objects the simulator does not interpret with the version of the request, keeping only objects
+// of the namespace of namespaced list paths
+func (sh *SchedulerHandler) opaqueList(kind, group string, list func() []model.OpaqueObject) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiVersion := group + "/" + mux.Vars(r)["version"]
+		namespace := mux.Vars(r)["namespace"]
+
+		items := make([]opaqueObject, 0)
+		for _, object := range list() {
+			if namespace == "" || object.Namespace == namespace {
+				items = append(items, opaqueObject{
+					TypeMeta:     unversioned.TypeMeta{Kind: kind, APIVersion: apiVersion},
+					OpaqueObject: object,
+				})
+			}
+		}
+
+		listJSON, err := json.Marshal(&opaqueList{
+			TypeMeta: unversioned.TypeMeta{Kind: kind + "List", APIVersion: apiVersion},
+			ListMeta: unversioned.ListMeta{ResourceVersion: strconv.FormatInt(sh.brain.GetResourceVersion(), 10)},
+			Items:    items,
+		})
+		if err != nil {
+			sh.handleError(marshallingError("opaqueList", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(listJSON)
+	}
+}
+
+func (sh *SchedulerHandler) getPriorityClasses(w http.ResponseWriter, r *http.Request) {
+	priorityClasses := sh.brain.GetPriorityClasses()
+	apiVersion := "scheduling.k8s.io/" + mux.Vars(r)["version"]
 
-	replicasetsJSON, err := json.Marshal(&replicasets)
+	items := make([]priorityClass, len(priorityClasses))
+	for i, class := range priorityClasses {
+		items[i] = priorityClass{
+			TypeMeta:      unversioned.TypeMeta{Kind: "PriorityClass", APIVersion: apiVersion},
+			PriorityClass: class,
+		}
+	}
+
+	priorityClassesJSON, err := json.Marshal(&priorityClassList{
+		TypeMeta: unversioned.TypeMeta{Kind: "PriorityClassList", APIVersion: apiVersion},
+		ListMeta: unversioned.ListMeta{ResourceVersion: strconv.FormatInt(sh.brain.GetResourceVersion(), 10)},
+		Items:    items,
+	})
 	if err != nil {
-		sh.handleError(marshallingError("getReplicasets", err))
+		sh.handleError(marshallingError("getPriorityClasses", err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(replicasetsJSON)
+	w.Write(priorityClassesJSON)
 }
 
-func (sh *SchedulerHandler) getServices(w http.ResponseWriter, r *http.Request) {
-	services := sh.brain.GetServices()
+func (sh *SchedulerHandler) getStorageClasses(w http.ResponseWriter, r *http.Request) {
+	storageClasses := sh.brain.GetStorageClasses()
+	apiVersion := "storage.k8s.io/" + mux.Vars(r)["version"]
+
+	items := make([]storageClass, len(storageClasses))
+	for i, class := range storageClasses {
+		items[i] = storageClass{
+			TypeMeta:     unversioned.TypeMeta{Kind: "StorageClass", APIVersion: apiVersion},
+			StorageClass: class,
+		}
+	}
 
-	servicesJSON, err := json.Marshal(&services)
+	storageClassesJSON, err := json.Marshal(&storageClassList{
+		TypeMeta: unversioned.TypeMeta{Kind: "StorageClassList", APIVersion: apiVersion},
+		ListMeta: unversioned.ListMeta{ResourceVersion: strconv.FormatInt(sh.brain.GetResourceVersion(), 10)},
+		Items:    items,
+	})
 	if err != nil {
-		sh.handleError(marshallingError("getServices", err))
+		sh.handleError(marshallingError("getStorageClasses", err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(servicesJSON)
+	w.Write(storageClassesJSON)
 }
 
-func (sh *SchedulerHandler) getReplicationControllers(w http.ResponseWriter, r *http.Request) {
-	replicationControllers := sh.brain.GetReplicationControllers()
+// Removes objects of other namespaces from the list, lists are not filtered if the namespace is not given
+func filterNamespace(list runtime.Object, namespace string) error {
+	if namespace == "" {
+		return nil
+	}
 
-	replicationControllersJSON, err := json.Marshal(&replicationControllers)
+	objects, err := meta.ExtractList(list)
 	if err != nil {
-		sh.handleError(marshallingError("getReplicationControllers", err))
-		return
+		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(replicationControllersJSON)
+	filtered := make([]runtime.Object, 0, len(objects))
+	for _, object := range objects {
+		if inNamespace(object, namespace) {
+			filtered = append(filtered, object)
+		}
+	}
+
+	return meta.SetList(list, filtered)
+}
+
+func inNamespace(object runtime.Object, namespace string) bool {
+	accessor, err := meta.Accessor(object)
+	return err == nil && accessor.GetNamespace() == namespace
 }
 
 func (sh *SchedulerHandler) handleError(err error) {
@@ -239,6 +482,19 @@ func (sh *SchedulerHandler) handleError(err error) {
 	sh.errChan <- errMsg
 }
 
+func tooOldResourceVersionStatus() *unversioned.Status {
+	return &unversioned.Status{
+		TypeMeta: unversioned.TypeMeta{
+			Kind:       "Status",
+			APIVersion: "v1",
+		},
+		Status:  unversioned.StatusFailure,
+		Message: state.ErrResourceVersionTooOld.Error(),
+		Reason:  unversioned.StatusReasonGone,
+		Code:    http.StatusGone,
+	}
+}
+
 func marshallingError(handlerName string, err error) error {
 	return fmt.Errorf("error marshalling response in %s: %s", handlerName, err)
 }
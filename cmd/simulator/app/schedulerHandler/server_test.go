@@ -0,0 +1,157 @@
+package schedulerHandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/watch"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/brain"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/kubeClient"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+func newTestHandler(t *testing.T, snapshot *model.Snapshot) *SchedulerHandler {
+	clusterState, err := state.InitState(kubeClient.NewSnapshotFetcher(snapshot), nil)
+	assert.NoError(t, err)
+
+	b := brain.New(clusterState, make(chan *v1.Event, 10), 1)
+	return New(b, "0", make(chan error, 10))
+}
+
+func get(sh *SchedulerHandler, path string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest("GET", path, nil)
+	recorder := httptest.NewRecorder()
+	sh.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestReplicationControllersListed(t *testing.T) {
+	sh := newTestHandler(t, &model.Snapshot{
+		ReplicationControllers: []v1.ReplicationController{{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "rc"}}},
+		Services:               []v1.Service{{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "svc"}}},
+	})
+
+	recorder := get(sh, "/api/v1/replicationcontrollers")
+
+	var list v1.ReplicationControllerList
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "rc", list.Items[0].Name)
+	}
+}
+
+func TestNamespacedListsFiltered(t *testing.T) {
+	sh := newTestHandler(t, &model.Snapshot{
+		Pods: []v1.Pod{
+			{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "web"}},
+			{ObjectMeta: v1.ObjectMeta{Namespace: "kube-system", Name: "dns"}},
+		},
+		Services: []v1.Service{
+			{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "web"}},
+			{ObjectMeta: v1.ObjectMeta{Namespace: "kube-system", Name: "dns"}},
+		},
+	})
+
+	var pods v1.PodList
+	recorder := get(sh, "/api/v1/namespaces/kube-system/pods")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &pods))
+	if assert.Len(t, pods.Items, 1) {
+		assert.Equal(t, "dns", pods.Items[0].Name)
+	}
+
+	var services v1.ServiceList
+	recorder = get(sh, "/api/v1/namespaces/default/services")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &services))
+	if assert.Len(t, services.Items, 1) {
+		assert.Equal(t, "web", services.Items[0].Name)
+	}
+
+	// Single pods are still served by their own route
+	recorder = get(sh, "/api/v1/namespaces/default/pods/web")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestStatefulSetsAndPodDisruptionBudgetsListed(t *testing.T) {
+	spec := json.RawMessage(`{"minAvailable":1,"selector":{"matchLabels":{"app":"db"}}}`)
+	sh := newTestHandler(t, &model.Snapshot{
+		StatefulSets: []model.OpaqueObject{{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "db"}}},
+		PodDisruptionBudgets: []model.OpaqueObject{
+			{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "db"}, Spec: spec},
+			{ObjectMeta: v1.ObjectMeta{Namespace: "kube-system", Name: "dns"}},
+		},
+	})
+
+	for path, kind := range map[string]string{
+		"/apis/apps/v1beta1/statefulsets":                              "StatefulSet",
+		"/apis/policy/v1beta1/namespaces/default/poddisruptionbudgets": "PodDisruptionBudget",
+	} {
+		recorder := get(sh, path)
+
+		var list opaqueList
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+		assert.Equal(t, kind+"List", list.Kind)
+		if assert.Len(t, list.Items, 1, path) {
+			assert.Equal(t, kind, list.Items[0].Kind)
+			assert.Equal(t, "db", list.Items[0].Name)
+		}
+	}
+
+	// Objects are served as they were fetched
+	recorder := get(sh, "/apis/policy/v1beta1/poddisruptionbudgets")
+	var list opaqueList
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+	if assert.Len(t, list.Items, 2) {
+		assert.JSONEq(t, string(spec), string(list.Items[0].Spec))
+	}
+}
+
+func TestStorageClassesListed(t *testing.T) {
+	sh := newTestHandler(t, &model.Snapshot{
+		StorageClasses: []model.StorageClass{{ObjectMeta: v1.ObjectMeta{Name: "standard"}, Provisioner: "kubernetes.io/gce-pd"}},
+	})
+
+	recorder := get(sh, "/apis/storage.k8s.io/v1/storageclasses")
+
+	var list storageClassList
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "storage.k8s.io/v1", list.Items[0].APIVersion)
+		assert.Equal(t, "standard", list.Items[0].Name)
+	}
+}
+
+func TestWatchRequestedWithQueryParameter(t *testing.T) {
+	sh := newTestHandler(t, &model.Snapshot{
+		Pods: []v1.Pod{
+			{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "web"}},
+			{ObjectMeta: v1.ObjectMeta{Namespace: "kube-system", Name: "dns"}},
+		},
+	})
+
+	recorder := get(sh, "/api/v1/namespaces/kube-system/pods?watch=true&resourceVersion=0&timeoutSeconds=1")
+
+	var event struct {
+		Type   watch.EventType `json:"type"`
+		Object v1.Pod          `json:"object"`
+	}
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	decoder := json.NewDecoder(recorder.Body)
+	if assert.NoError(t, decoder.Decode(&event)) {
+		assert.Equal(t, watch.Added, event.Type)
+		assert.Equal(t, "dns", event.Object.Name)
+	}
+	assert.False(t, decoder.More())
+}
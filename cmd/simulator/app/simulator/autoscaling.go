@@ -0,0 +1,178 @@
+package simulator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/deckarep/golang-set"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Estimates, for every template separately, how many of its nodes have to be added to the cluster for pods
+// that failed scheduling to fit. The maximum number of nodes is tried first, then the minimal number of nodes
+// that fits as many pods is searched for. Every try schedules the pods again on a copy of the state after
+// the simulation, with the nodes added. The estimation shares the deadline of the simulation,
+// the state after the simulation is restored afterwards.
+func (s *Simulator) estimateNodes(templates []model.NodeTemplate, results []*model.SchedulingResult,
+	deadline <-chan time.Time, timeout time.Duration) ([]model.NodeEstimate, error) {
+	estimates := make([]model.NodeEstimate, len(templates))
+	err := s.withStateRestored(func(after *model.Snapshot) error {
+		base, pods := splitFailedPods(after, results)
+
+		for i, template := range templates {
+			estimate, err := s.estimateTemplateNodes(template, base, pods, deadline, timeout)
+			if err != nil {
+				return err
+			}
+			estimates[i] = *estimate
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return estimates, nil
+}
+
+// Splits pods of the state after the simulation into pods that failed scheduling and the state without them.
+// Pods that failed scheduling are added again in every try, so that the scheduler reports them.
+func splitFailedPods(after *model.Snapshot, results []*model.SchedulingResult) (*model.Snapshot, []v1.Pod) {
+	failedPods := make(map[string]bool)
+	for _, result := range results {
+		if result != nil && result.Result == failedSchedulingReason {
+			failedPods[result.PodName] = true
+		}
+	}
+
+	base := *after
+	base.Pods = nil
+	var pods []v1.Pod
+	for _, pod := range after.Pods {
+		if failedPods[model.PodKey(pod.Namespace, pod.Name)] {
+			pods = append(pods, pod)
+		} else {
+			base.Pods = append(base.Pods, pod)
+		}
+	}
+
+	return &base, pods
+}
+
+func (s *Simulator) estimateTemplateNodes(template model.NodeTemplate, base *model.Snapshot, pods []v1.Pod,
+	deadline <-chan time.Time, timeout time.Duration) (*model.NodeEstimate, error) {
+	maxNodes := template.Count
+	if maxNodes <= 0 {
+		maxNodes = model.DefaultMaxEstimatedNodes
+	}
+	estimate := &model.NodeEstimate{Template: template.Name(), MaxNodes: maxNodes}
+
+	if len(pods) == 0 {
+		return estimate, nil
+	}
+
+	scheduledWithMax, timedOut, err := s.tryNodes(template, maxNodes, base, pods, deadline, timeout)
+	if err != nil {
+		return nil, err
+	}
+	estimate.Nodes = maxNodes
+	for _, pod := range pods {
+		podKey := model.PodKey(pod.Namespace, pod.Name)
+		if !scheduledWithMax.Contains(podKey) {
+			estimate.Unschedulable = append(estimate.Unschedulable, podKey)
+		}
+	}
+	sort.Strings(estimate.Unschedulable)
+
+	if timedOut {
+		estimate.Message = fmt.Sprintf("estimation did not finish within %s", timeout)
+		return estimate, nil
+	}
+	if scheduledWithMax.Cardinality() == 0 {
+		estimate.Nodes = 0
+		estimate.Message = fmt.Sprintf("no pod fits on %d nodes of the template", maxNodes)
+		return estimate, nil
+	}
+
+	// The number of scheduled pods does not decrease with the number of nodes
+	low, high := 0, maxNodes
+	for high-low > 1 {
+		nodes := (low + high) / 2
+		scheduled, timedOut, err := s.tryNodes(template, nodes, base, pods, deadline, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if timedOut {
+			estimate.Nodes = high
+			estimate.Message = fmt.Sprintf("estimation did not finish within %s, %d nodes may be more than needed",
+				timeout, high)
+			return estimate, nil
+		}
+
+		if scheduled.Cardinality() >= scheduledWithMax.Cardinality() {
+			high = nodes
+		} else {
+			low = nodes
+		}
+	}
+	estimate.Nodes = high
+
+	return estimate, nil
+}
+
+// Schedules pods on the base state with the given number of nodes of the template added.
+// Returns keys of pods that were scheduled and whether the deadline passed before all pods were processed.
+func (s *Simulator) tryNodes(template model.NodeTemplate, nodes int, base *model.Snapshot, pods []v1.Pod,
+	deadline <-chan time.Time, timeout time.Duration) (mapset.Set, bool, error) {
+	select {
+	case <-deadline:
+		return mapset.NewSet(), true, nil
+	default:
+	}
+	log.Printf("Trying to schedule %d pods with %d nodes of template %s", len(pods), nodes, template.Name())
+
+	if err := s.resetState(base); err != nil {
+		return nil, false, err
+	}
+	// Events of pods of the previous try would be taken for results of this one
+	s.discardPendingMessages()
+
+	template.Count = nodes
+	if _, err := s.brain.ApplyNodeMutations(&model.NodeMutations{Add: []model.NodeTemplate{template}}); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.waitForSchedulerSync(deadline, timeout); err != nil {
+		return nil, false, err
+	}
+
+	results := make(map[string]*model.SchedulingResult, len(pods))
+	podsToProcess := mapset.NewSet()
+	for i := range pods {
+		if _, err := s.addPod(&pods[i], results, podsToProcess); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := s.waitForResults(results, podsToProcess, deadline, timeout); err != nil {
+		return nil, false, err
+	}
+
+	scheduled := mapset.NewSet()
+	timedOut := false
+	for podKey, result := range results {
+		switch result.Result {
+		case scheduledReason:
+			scheduled.Add(podKey)
+		case model.TimedOutResult:
+			timedOut = true
+		}
+	}
+
+	return scheduled, timedOut, nil
+}
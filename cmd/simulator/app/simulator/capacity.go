@@ -0,0 +1,33 @@
+package simulator
+
+import (
+	"sort"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Joins resources of nodes from before and after the simulation, nodes are sorted by name
+func nodeCapacities(allocatable, requestedBefore, requestedAfter map[string]model.Resources) []model.NodeCapacity {
+	nodeNames := make([]string, 0, len(allocatable))
+	for nodeName := range allocatable {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	for nodeName := range requestedBefore {
+		if _, ok := allocatable[nodeName]; !ok {
+			nodeNames = append(nodeNames, nodeName)
+		}
+	}
+	sort.Strings(nodeNames)
+
+	capacity := make([]model.NodeCapacity, len(nodeNames))
+	for i, nodeName := range nodeNames {
+		capacity[i] = model.NodeCapacity{
+			NodeName:        nodeName,
+			Allocatable:     allocatable[nodeName],
+			RequestedBefore: requestedBefore[nodeName],
+			RequestedAfter:  requestedAfter[nodeName],
+		}
+	}
+
+	return capacity
+}
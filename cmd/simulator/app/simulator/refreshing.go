@@ -0,0 +1,67 @@
+package simulator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/brain"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Fetches a fresh snapshot of the cluster state
+type StateFetchFunc func() (*state.ClusterState, error)
+
+// SimulationRunner used by long-lived simulators. Before each simulation and analysis the state of the brain
+// is replaced with a fresh snapshot of the cluster, so results of the previous simulations do not affect the next ones.
+type RefreshingSimulator struct {
+	sync.Mutex
+	simulator  SimulationRunner
+	brain      *brain.Brain
+	fetchState StateFetchFunc
+}
+
+func NewRefreshing(simulator SimulationRunner, brain *brain.Brain, fetchState StateFetchFunc) SimulationRunner {
+	return &RefreshingSimulator{
+		simulator:  simulator,
+		brain:      brain,
+		fetchState: fetchState,
+	}
+}
+
+func (rs *RefreshingSimulator) RunMultiplePodSimulation(podsToCreate, toDelete []*v1.Pod,
+	claims []*v1.PersistentVolumeClaim, nodeMutations *model.NodeMutations, nodeTemplates []model.NodeTemplate, timeout time.Duration) (*model.SimulatorResponse, error) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	if err := rs.refresh(); err != nil {
+		return nil, err
+	}
+
+	return rs.simulator.RunMultiplePodSimulation(podsToCreate, toDelete, claims, nodeMutations, nodeTemplates, timeout)
+}
+
+func (rs *RefreshingSimulator) RunResilienceAnalysis(domainLabel string, timeout time.Duration) (*model.ResilienceResponse, error) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	if err := rs.refresh(); err != nil {
+		return nil, err
+	}
+
+	return rs.simulator.RunResilienceAnalysis(domainLabel, timeout)
+}
+
+func (rs *RefreshingSimulator) refresh() error {
+	fresh, err := rs.fetchState()
+	if err != nil {
+		return fmt.Errorf("error fetching cluster state: %s", err)
+	}
+
+	rs.brain.ResetState(fresh)
+
+	return nil
+}
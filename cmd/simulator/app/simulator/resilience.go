@@ -0,0 +1,192 @@
+package simulator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/deckarep/golang-set"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+	"github.com/Prytu/risk-advisor/pkg/workloads"
+)
+
+// Simulates the failure of every failure domain separately: all nodes of the domain are removed and their pods
+// are scheduled again on the remaining nodes, the same way as pods of nodes removed with NodeMutations.
+// Workloads that do not get all their lost pods back are reported as unrecovered. The whole analysis has to finish
+// within the timeout, domains not analysed by then are reported with a message. The state of the cluster
+// is restored afterwards.
+func (s *Simulator) RunResilienceAnalysis(domainLabel string, timeout time.Duration) (*model.ResilienceResponse, error) {
+	s.discardPendingMessages()
+	s.startSchedulerServer()
+
+	replicaSets := s.brain.GetReplicasets().Items
+
+	deadline, stopTimer := newDeadline(timeout)
+	defer stopTimer()
+
+	response := &model.ResilienceResponse{DomainLabel: domainLabel, Domains: []model.DomainFailure{}}
+	err := s.withStateRestored(func(before *model.Snapshot) error {
+		for _, domain := range failureDomains(before.Nodes, domainLabel) {
+			failure, err := s.simulateDomainFailure(domain, before, replicaSets, deadline, timeout)
+			if err != nil {
+				return err
+			}
+
+			if len(failure.Unrecovered) > 0 {
+				response.Unrecoverable = append(response.Unrecoverable, failure.Domain)
+			}
+			response.Domains = append(response.Domains, *failure)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (s *Simulator) simulateDomainFailure(domain model.DomainFailure, base *model.Snapshot,
+	replicaSets []v1beta1.ReplicaSet, deadline <-chan time.Time, timeout time.Duration) (*model.DomainFailure, error) {
+	select {
+	case <-deadline:
+		domain.Message = fmt.Sprintf("not analysed, the analysis did not finish within %s", timeout)
+		return &domain, nil
+	default:
+	}
+	log.Printf("Simulating failure of domain %s (nodes: %v)", domain.Domain, domain.Nodes)
+
+	if err := s.resetState(base); err != nil {
+		return nil, err
+	}
+	s.brain.ResetPreemptions()
+
+	lostPods, err := s.brain.ApplyNodeMutations(&model.NodeMutations{Remove: domain.Nodes})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.waitForSchedulerSync(deadline, timeout); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*model.SchedulingResult, len(lostPods))
+	podKeys := make([]string, 0, len(lostPods))
+	podsToProcess := mapset.NewSet()
+	for i := range lostPods {
+		podKey, err := s.addPod(&lostPods[i], results, podsToProcess)
+		if err != nil {
+			return nil, err
+		}
+		podKeys = append(podKeys, podKey)
+	}
+
+	if err := s.waitForResults(results, podsToProcess, deadline, timeout); err != nil {
+		return nil, err
+	}
+	domain.Results = s.collectResults(results, podKeys)
+
+	// Pods preempted to make room for the lost pods are lost as well
+	basePods := make(map[string]*v1.Pod, len(base.Pods))
+	for i := range base.Pods {
+		basePods[model.PodKey(base.Pods[i].Namespace, base.Pods[i].Name)] = &base.Pods[i]
+	}
+	for _, result := range domain.Results {
+		for _, victim := range result.PreemptedPods {
+			if pod, ok := basePods[victim]; ok {
+				lostPods = append(lostPods, *pod)
+			}
+		}
+	}
+
+	domain.Workloads, domain.Unrecovered = summarizeLostPods(lostPods, domain.Results, replicaSets)
+
+	return &domain, nil
+}
+
+// Groups nodes by the value of the domain label, or returns a domain for every node if the label is not given.
+// Nodes without the label are domains of their own. Domains are sorted by name, so are nodes of every domain.
+func failureDomains(nodes []v1.Node, domainLabel string) []model.DomainFailure {
+	nodesByDomain := make(map[string][]string)
+	var domains []model.DomainFailure
+	for _, node := range nodes {
+		if domainLabel == "" {
+			domains = append(domains, model.DomainFailure{Domain: node.Name, Nodes: []string{node.Name}})
+			continue
+		}
+
+		domain, ok := node.Labels[domainLabel]
+		if !ok {
+			domains = append(domains, model.DomainFailure{Domain: node.Name, Nodes: []string{node.Name}, Unlabeled: true})
+			continue
+		}
+		nodesByDomain[domain] = append(nodesByDomain[domain], node.Name)
+	}
+
+	for domain, domainNodes := range nodesByDomain {
+		sort.Strings(domainNodes)
+		domains = append(domains, model.DomainFailure{Domain: domain, Nodes: domainNodes})
+	}
+	sort.Sort(domainsByName(domains))
+
+	return domains
+}
+
+// Summarizes lost pods per workload, workloads are sorted by kind and name. Returns also workloads
+// that do not get all their lost pods back, in "Kind namespace/name" format.
+func summarizeLostPods(lostPods []v1.Pod, results []*model.SchedulingResult,
+	replicaSets []v1beta1.ReplicaSet) ([]model.WorkloadResult, []string) {
+	scheduledPods := make(map[string]bool, len(results))
+	for _, result := range results {
+		if result.Result == scheduledReason {
+			scheduledPods[result.PodName] = true
+		}
+	}
+
+	summaryByWorkload := make(map[string]*model.WorkloadResult)
+	var keys []string
+	for i := range lostPods {
+		kind, name := workloads.Owner(&lostPods[i], replicaSets)
+		key := fmt.Sprintf("%s %s", kind, name)
+
+		summary, ok := summaryByWorkload[key]
+		if !ok {
+			summary = &model.WorkloadResult{Kind: kind, Name: name}
+			summaryByWorkload[key] = summary
+			keys = append(keys, key)
+		}
+		summary.Replicas++
+		if scheduledPods[model.PodKey(lostPods[i].Namespace, lostPods[i].Name)] {
+			summary.Scheduled++
+		}
+	}
+	sort.Strings(keys)
+
+	var summaries []model.WorkloadResult
+	var unrecovered []string
+	for _, key := range keys {
+		summary := summaryByWorkload[key]
+		summary.Message = fmt.Sprintf("%d of %d lost replicas schedulable", summary.Scheduled, summary.Replicas)
+		summaries = append(summaries, *summary)
+		if summary.Scheduled < summary.Replicas {
+			unrecovered = append(unrecovered, key)
+		}
+	}
+
+	return summaries, unrecovered
+}
+
+type domainsByName []model.DomainFailure
+
+func (d domainsByName) Len() int      { return len(d) }
+func (d domainsByName) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+
+// Unlabeled nodes go after domains of the same name
+func (d domainsByName) Less(i, j int) bool {
+	return d[i].Domain < d[j].Domain || d[i].Domain == d[j].Domain && !d[i].Unlabeled && d[j].Unlabeled
+}
@@ -3,7 +3,14 @@ package simulator
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/deckarep/golang-set"
 	"k8s.io/client-go/1.5/pkg/api/v1"
@@ -11,11 +18,29 @@ import (
 
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/brain"
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/schedulerHandler"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/pkg/kubeClient"
 	"github.com/Prytu/risk-advisor/pkg/model"
 )
 
+const scheduledReason = "Scheduled"
+const failedSchedulingReason = "FailedScheduling"
+
+// FitError messages of kube-scheduler 1.4 and 1.5, one line per node
+var fitFailureRegexp = regexp.MustCompile(`^fit failure on node \((.*)\): (.*)$`)
+
+// FitError messages of kube-scheduler 1.6 and 1.7, with reasons like "Insufficient cpu (2)"
+var predicateCountsRegexp = regexp.MustCompile(`^No nodes are available that match all of the (?:following )?predicates:+ (.*?)\.?$`)
+var predicateCountRegexp = regexp.MustCompile(`^(.+) \((\d+)\)$`)
+
+// FitError messages of kube-scheduler 1.8 and later, with reasons like "2 node(s) didn't match node selector"
+var nodesAvailableRegexp = regexp.MustCompile(`^\d+/\d+ nodes are available: (.*?)\.?$`)
+var nodeCountRegexp = regexp.MustCompile(`^(\d+) (.+)$`)
+
 type SimulationRunner interface {
-	RunMultiplePodSimulation(podsToCreate, toDelete []*v1.Pod) ([]*model.SchedulingResult, error)
+	RunMultiplePodSimulation(podsToCreate, toDelete []*v1.Pod, claims []*v1.PersistentVolumeClaim,
+		nodeMutations *model.NodeMutations, nodeTemplates []model.NodeTemplate, timeout time.Duration) (*model.SimulatorResponse, error)
+	RunResilienceAnalysis(domainLabel string, timeout time.Duration) (*model.ResilienceResponse, error)
 }
 
 type Simulator struct {
@@ -23,85 +48,396 @@ type Simulator struct {
 	schedulerHandler *schedulerHandler.SchedulerHandler
 	eventChannel     <-chan *v1.Event
 	errorChannel     <-chan error
+	serverOnce       sync.Once
 
-	// Map pod.Name to the result of scheduling attempt of that pod
+	// Names of schedulers running in the simulator, pods of other schedulers are not simulated
+	schedulerNames mapset.Set
+
+	// Map pod key (see model.PodKey) to the result of scheduling attempt of that pod
 	RequestPods map[string]*model.SchedulingResult
 
-	// Set of pod.Name of pods from user's request that has not been processed yet
+	// Set of keys of pods from user's request that has not been processed yet
 	PodsLeftToProcess mapset.Set
 }
 
 func New(brain *brain.Brain, schedulerCommunicationServer *schedulerHandler.SchedulerHandler,
-	eventChannel <-chan *v1.Event, errorChannel <-chan error) SimulationRunner {
+	eventChannel <-chan *v1.Event, errorChannel <-chan error, schedulerNames []string) SimulationRunner {
+	names := mapset.NewSet()
+	for _, name := range schedulerNames {
+		names.Add(name)
+	}
+
 	return &Simulator{
 		brain:            brain,
 		schedulerHandler: schedulerCommunicationServer,
 		eventChannel:     eventChannel,
 		errorChannel:     errorChannel,
+		schedulerNames:   names,
 	}
 }
 
-func (s *Simulator) RunMultiplePodSimulation(podsToCreate, toDelete []*v1.Pod) ([]*model.SchedulingResult, error) {
+// Pods that do not get a scheduling decision before the timeout get TimedOut results,
+// results of the other pods are returned as usual. Pods of schedulers that do not run in the simulator
+// are not added to the state, they get UnsupportedScheduler results immediately. Results of pods that preempted
+// other pods list the preempted pods. Pods of removed and drained nodes are scheduled again together with
+// the new pods, their results are returned separately. If node templates are given, the number of nodes
+// needed by pods that failed scheduling is estimated afterwards, see estimateNodes. PVCs are bound before pods
+// are scheduled, see brain.BindClaims.
+func (s *Simulator) RunMultiplePodSimulation(podsToCreate, toDelete []*v1.Pod, claims []*v1.PersistentVolumeClaim,
+	nodeMutations *model.NodeMutations, nodeTemplates []model.NodeTemplate, timeout time.Duration) (*model.SimulatorResponse, error) {
+	s.discardPendingMessages()
+	s.brain.ResetPreemptions()
+
+	snapshot := s.brain.Snapshot()
+	requestedBefore := s.brain.GetRequestedResources()
+
+	deadline, stopTimer := newDeadline(timeout)
+	defer stopTimer()
+
 	requestPods := make(map[string]*model.SchedulingResult, len(podsToCreate))
+	requestPodKeys := make([]string, 0, len(podsToCreate))
+	evictedPodKeys := make([]string, 0)
 	podsToProcess := mapset.NewSet()
 
-	// Apply state mutations
+	// Apply state mutations. Deletions go first, so that a pod can be replaced by a new one with the same name.
+	for _, pod := range toDelete {
+		if pod.Namespace == "" {
+			pod.Namespace = v1.NamespaceDefault
+		}
+		if err := s.brain.RemovePodFromState(pod.Namespace, pod.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	evictedPods, err := s.brain.ApplyNodeMutations(nodeMutations)
+	if err != nil {
+		return nil, err
+	}
+
+	claimResults, err := s.brain.BindClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	s.startSchedulerServer()
+
+	if err := s.waitForSchedulerSync(deadline, timeout); err != nil {
+		return nil, err
+	}
+
 	for _, pod := range podsToCreate {
 		if pod.Name == "" {
 			pod.Name = utilrand.String(model.MaxNameLength)
 		}
-		requestPods[pod.Name] = nil
-		podsToProcess.Add(pod.Name)
-		s.brain.AddPodToState(*pod)
+		if pod.Namespace == "" {
+			pod.Namespace = v1.NamespaceDefault
+		}
+
+		podKey, err := s.addPod(pod, requestPods, podsToProcess)
+		if err != nil {
+			return nil, err
+		}
+		requestPodKeys = append(requestPodKeys, podKey)
 	}
 
-	// Run scheduler communication server
-	log.Printf("Starting scheduler server on port %s\n", s.schedulerHandler.Port)
-	go http.ListenAndServe(fmt.Sprintf(":%s", s.schedulerHandler.Port), s.schedulerHandler)
+	for i := range evictedPods {
+		podKey, err := s.addPod(&evictedPods[i], requestPods, podsToProcess)
+		if err != nil {
+			return nil, err
+		}
+		evictedPodKeys = append(evictedPodKeys, podKey)
+	}
 
-L:
-	for {
+	if err := s.waitForResults(requestPods, podsToProcess, deadline, timeout); err != nil {
+		return nil, err
+	}
+
+	// Results are returned in the order of the request
+	results := s.collectResults(requestPods, requestPodKeys)
+	var evicted []*model.SchedulingResult
+	if len(evictedPodKeys) > 0 {
+		evicted = s.collectResults(requestPods, evictedPodKeys)
+	}
+
+	capacity := nodeCapacities(s.brain.GetAllocatableResources(), requestedBefore, s.brain.GetRequestedResources())
+
+	var autoscaling []model.NodeEstimate
+	if len(nodeTemplates) > 0 {
+		if autoscaling, err = s.estimateNodes(nodeTemplates, append(results, evicted...), deadline, timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	return &model.SimulatorResponse{
+		Results:                results,
+		Capacity:               capacity,
+		Evicted:                evicted,
+		Autoscaling:            autoscaling,
+		PersistentVolumeClaims: claimResults,
+		Snapshot:               snapshot,
+	}, nil
+}
+
+// Deadline of a simulation, which is closed instead of sending a single value, so that every step
+// after the deadline times out immediately. The returned function stops the timer.
+func newDeadline(timeout time.Duration) (<-chan time.Time, func() bool) {
+	deadline := make(chan time.Time)
+	timer := time.AfterFunc(timeout, func() { close(deadline) })
+
+	return deadline, timer.Stop
+}
+
+// Runs an analysis made of many simulations, each of them started from a changed copy of the current state,
+// see resetState. The current state is restored afterwards.
+func (s *Simulator) withStateRestored(analyse func(current *model.Snapshot) error) error {
+	current := s.brain.Snapshot()
+	if err := analyse(current); err != nil {
+		return err
+	}
+
+	return s.resetState(current)
+}
+
+func (s *Simulator) resetState(snapshot *model.Snapshot) error {
+	fresh, err := state.InitState(kubeClient.NewSnapshotFetcher(snapshot), nil)
+	if err != nil {
+		return fmt.Errorf("error restoring cluster state: %s", err)
+	}
+	s.brain.ResetState(fresh)
+
+	return nil
+}
+
+// Runs scheduler communication server, it keeps running between simulations. Scheduler runs next to the simulator,
+// in the same pod or on the same host, so the server listens on the loopback interface only.
+func (s *Simulator) startSchedulerServer() {
+	s.serverOnce.Do(func() {
+		log.Printf("Starting scheduler server on port %s\n", s.schedulerHandler.Port)
+		go http.ListenAndServe(net.JoinHostPort("127.0.0.1", s.schedulerHandler.Port), s.schedulerHandler)
+	})
+}
+
+// Makes the scheduler list the current state and waits until it does. Pods to schedule are added only
+// when scheduler knows all nodes and scheduled pods, otherwise they could be scheduled against an incomplete view
+// of the cluster.
+func (s *Simulator) waitForSchedulerSync(deadline <-chan time.Time, timeout time.Duration) error {
+	s.brain.ForceRelist()
+	select {
+	case <-s.brain.SchedulerSynced():
+		return nil
+	case err := <-s.errorChannel:
+		return err
+	case <-deadline:
+		return fmt.Errorf("scheduler did not fetch the cluster state within %s", timeout)
+	}
+}
+
+// Adds the pod to the state and to pods to process, unless it is handled by a scheduler that does not run
+// in the simulator. Returns the key of the pod.
+func (s *Simulator) addPod(pod *v1.Pod, results map[string]*model.SchedulingResult, podsToProcess mapset.Set) (string, error) {
+	podKey := model.PodKey(pod.Namespace, pod.Name)
+
+	if schedulerName := model.PodSchedulerName(pod); !s.schedulerNames.Contains(schedulerName) {
+		results[podKey] = s.unsupportedSchedulerResult(podKey, schedulerName)
+		return podKey, nil
+	}
+
+	if err := s.brain.AddPodToState(*pod); err != nil {
+		return "", err
+	}
+
+	results[podKey] = nil
+	podsToProcess.Add(podKey)
+
+	return podKey, nil
+}
+
+// Receives scheduling events until all pods are processed. Pods without a result at the deadline get TimedOut
+// results, nominated pods keep the result of their last scheduling attempt.
+func (s *Simulator) waitForResults(results map[string]*model.SchedulingResult, podsToProcess mapset.Set,
+	deadline <-chan time.Time, timeout time.Duration) error {
+	for podsToProcess.Cardinality() > 0 {
 		select {
 		case event := <-s.eventChannel:
-			podName := event.InvolvedObject.Name
+			podKey := model.PodKey(event.InvolvedObject.Namespace, event.InvolvedObject.Name)
 			schedulingResult := schedulingResultFromEvent(event)
 
-			if _, ok := requestPods[podName]; ok {
-				requestPods[podName] = schedulingResult
-				podsToProcess.Remove(podName)
-			} else {
+			if _, ok := results[podKey]; !ok {
 				log.Printf(`
 			Received pod scheduling event of a pod unrelated to request:
-			podName: %s
-			schedulingResult: %v`, podName, schedulingResult)
+			pod: %s
+			schedulingResult: %v`, podKey, schedulingResult)
+				continue
 			}
 
-			if podsToProcess.Cardinality() == 0 {
-				break L
+			if schedulingResult.Result == scheduledReason {
+				schedulingResult.NodeName = s.boundNodeName(event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+			}
+			results[podKey] = schedulingResult
+			if schedulingResult.Result == failedSchedulingReason && s.isNominated(podKey) {
+				// Scheduler makes room for the pod by preempting other pods, the pod is scheduled once they are gone
+				continue
 			}
+			podsToProcess.Remove(podKey)
 		case err := <-s.errorChannel:
-			return nil, err
+			return err
+		case <-deadline:
+			log.Printf("Simulation timed out after %s, %d pods were not processed", timeout, podsToProcess.Cardinality())
+			for podKey := range podsToProcess.Iter() {
+				if results[podKey.(string)] == nil {
+					results[podKey.(string)] = timedOutResult(podKey.(string), timeout)
+				}
+			}
+			return nil
 		}
 	}
 
-	results := make([]*model.SchedulingResult, len(requestPods))
-	i := 0
-	for _, result := range requestPods {
-		results[i] = result
-		i++
+	return nil
+}
+
+func (s *Simulator) collectResults(results map[string]*model.SchedulingResult, podKeys []string) []*model.SchedulingResult {
+	collected := make([]*model.SchedulingResult, len(podKeys))
+	for i, podKey := range podKeys {
+		collected[i] = results[podKey]
+		collected[i].NominatedNodeName, collected[i].PreemptedPods = s.brain.Preemptions(podKey)
 	}
 
-	return results, nil
+	return collected
+}
+
+// Events and errors that are still waiting to be received come from previous simulations.
+func (s *Simulator) discardPendingMessages() {
+	for {
+		select {
+		case event := <-s.eventChannel:
+			log.Printf("Discarding event from previous simulation: %s %s", event.InvolvedObject.Name, event.Reason)
+		case err := <-s.errorChannel:
+			log.Printf("Discarding error from previous simulation: %s", err)
+		default:
+			return
+		}
+	}
+}
+
+// Scheduler sends the Scheduled event after binding the pod, so the pod in the state is already assigned to the node
+func (s *Simulator) boundNodeName(namespace, podName string) string {
+	pod, err := s.brain.GetPod(namespace, podName)
+	if err != nil {
+		log.Printf("Error fetching scheduled pod: %s", err)
+		return ""
+	}
+
+	return pod.Spec.NodeName
+}
+
+func (s *Simulator) isNominated(podKey string) bool {
+	nominatedNode, _ := s.brain.Preemptions(podKey)
+	return nominatedNode != ""
+}
+
+func (s *Simulator) unsupportedSchedulerResult(podKey, schedulerName string) *model.SchedulingResult {
+	var names []string
+	for name := range s.schedulerNames.Iter() {
+		names = append(names, name.(string))
+	}
+	sort.Strings(names)
+
+	return &model.SchedulingResult{
+		PodName: podKey,
+		Result:  model.UnsupportedSchedulerResult,
+		Message: fmt.Sprintf("pod is handled by scheduler %s, which does not run in the simulator (simulated schedulers: %s)",
+			schedulerName, strings.Join(names, ", ")),
+	}
+}
+
+func timedOutResult(podKey string, timeout time.Duration) *model.SchedulingResult {
+	return &model.SchedulingResult{
+		PodName: podKey,
+		Result:  model.TimedOutResult,
+		Message: fmt.Sprintf("no scheduling decision within %s", timeout),
+	}
 }
 
 func schedulingResultFromEvent(event *v1.Event) *model.SchedulingResult {
 	result := event.Reason
 	message := event.Message
-	podName := event.InvolvedObject.Name
+	podKey := model.PodKey(event.InvolvedObject.Namespace, event.InvolvedObject.Name)
 
-	return &model.SchedulingResult{
-		PodName: podName,
+	schedulingResult := &model.SchedulingResult{
+		PodName: podKey,
 		Result:  result,
 		Message: message,
 	}
+
+	if result == failedSchedulingReason {
+		schedulingResult.FailedPredicates = failedPredicatesFromMessage(message)
+		if schedulingResult.FailedPredicates == nil {
+			schedulingResult.FailedPredicateCounts = failedPredicateCountsFromMessage(message)
+		}
+	}
+
+	return schedulingResult
+}
+
+// Parses the message of FitError of kube-scheduler 1.4 and 1.5, which contains a line
+// "fit failure on node (<node>): <reason>, <reason>..." for every node the pod does not fit on.
+// Newer schedulers only report the number of nodes per reason, see failedPredicateCountsFromMessage.
+func failedPredicatesFromMessage(message string) map[string][]string {
+	failedPredicates := make(map[string][]string)
+
+	for _, line := range strings.Split(message, "\n") {
+		match := fitFailureRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		var reasons []string
+		for _, reason := range strings.Split(match[2], ",") {
+			if reason = strings.TrimSpace(reason); reason != "" {
+				reasons = append(reasons, reason)
+			}
+		}
+		failedPredicates[match[1]] = reasons
+	}
+
+	if len(failedPredicates) == 0 {
+		return nil
+	}
+
+	return failedPredicates
+}
+
+// Parses the message of FitError of kube-scheduler 1.6 and later, which lists the number of nodes every reason
+// failed on, e.g. "0/3 nodes are available: 1 Insufficient cpu, 2 node(s) didn't match node selector."
+func failedPredicateCountsFromMessage(message string) map[string]int {
+	message = strings.TrimSpace(message)
+	counts := make(map[string]int)
+
+	if match := nodesAvailableRegexp.FindStringSubmatch(message); match != nil {
+		addPredicateCounts(counts, match[1], nodeCountRegexp, 1, 2)
+	} else if match := predicateCountsRegexp.FindStringSubmatch(message); match != nil {
+		addPredicateCounts(counts, match[1], predicateCountRegexp, 2, 1)
+	}
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	return counts
+}
+
+// Adds counts of comma separated reasons, which reasonRegexp splits into the count and the reason.
+func addPredicateCounts(counts map[string]int, reasons string, reasonRegexp *regexp.Regexp, countIndex, reasonIndex int) {
+	for _, reason := range strings.Split(reasons, ",") {
+		match := reasonRegexp.FindStringSubmatch(strings.TrimSpace(reason))
+		if match == nil {
+			continue
+		}
+
+		count, err := strconv.Atoi(match[countIndex])
+		if err != nil {
+			continue
+		}
+		counts[match[reasonIndex]] += count
+	}
 }
@@ -0,0 +1,807 @@
+package simulator
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/resource"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/fields"
+	"k8s.io/client-go/1.5/pkg/watch"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/brain"
+	mocks "github.com/Prytu/risk-advisor/cmd/simulator/app/mock"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/schedulerHandler"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state/fieldselectors"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// ClusterStateFetcher serving a fixed set of objects
+type fakeStateFetcher struct {
+	nodes           []v1.Node
+	pods            []v1.Pod
+	pvs             []v1.PersistentVolume
+	priorityClasses []model.PriorityClass
+	storageClasses  []model.StorageClass
+}
+
+func (f *fakeStateFetcher) GetPVCs(namespace string) (*v1.PersistentVolumeClaimList, error) {
+	return &v1.PersistentVolumeClaimList{}, nil
+}
+
+func (f *fakeStateFetcher) GetPVs() (*v1.PersistentVolumeList, error) {
+	return &v1.PersistentVolumeList{Items: f.pvs}, nil
+}
+
+func (f *fakeStateFetcher) GetReplicaSets(namespace string) (*v1beta1.ReplicaSetList, error) {
+	return &v1beta1.ReplicaSetList{}, nil
+}
+
+func (f *fakeStateFetcher) GetServices(namespace string) (*v1.ServiceList, error) {
+	return &v1.ServiceList{}, nil
+}
+
+func (f *fakeStateFetcher) GetReplicationControllers(namespace string) (*v1.ReplicationControllerList, error) {
+	return &v1.ReplicationControllerList{}, nil
+}
+
+func (f *fakeStateFetcher) GetPods(namespace string, fieldSelector fields.Selector) (*v1.PodList, error) {
+	var pods []v1.Pod
+	for _, pod := range f.pods {
+		if fieldSelector.Matches(podFields(&pod)) {
+			pods = append(pods, pod)
+		}
+	}
+
+	return &v1.PodList{Items: pods}, nil
+}
+
+func (f *fakeStateFetcher) GetNodes() (*v1.NodeList, error) {
+	return &v1.NodeList{ListMeta: unversioned.ListMeta{ResourceVersion: "1"}, Items: f.nodes}, nil
+}
+
+func (f *fakeStateFetcher) GetPriorityClasses() ([]model.PriorityClass, error) {
+	return f.priorityClasses, nil
+}
+
+func (f *fakeStateFetcher) GetStorageClasses() ([]model.StorageClass, error) {
+	return f.storageClasses, nil
+}
+
+func (f *fakeStateFetcher) GetStatefulSets(namespace string) ([]model.OpaqueObject, error) {
+	return nil, nil
+}
+
+func (f *fakeStateFetcher) GetPodDisruptionBudgets(namespace string) ([]model.OpaqueObject, error) {
+	return nil, nil
+}
+
+func podFields(pod *v1.Pod) fields.Set {
+	return fields.Set{
+		"spec.nodeName": pod.Spec.NodeName,
+		"status.phase":  string(pod.Status.Phase),
+	}
+}
+
+func newNode(name string, maxPods int64) v1.Node {
+	return v1.Node{
+		ObjectMeta: v1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{v1.ResourcePods: *resource.NewQuantity(maxPods, resource.DecimalSI)},
+		},
+	}
+}
+
+func newPod(name, nodeName string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: v1.NamespaceDefault, Name: name},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+// Scheduler talking to the brain the way kube-scheduler talks to the apiserver: it lists nodes and pods, watches them
+// and schedules pending pods on the least loaded node it knows about. Scheduling against an incomplete view
+// of the cluster gives different results, so it shows whether the simulator waits for the scheduler to sync.
+type fakeScheduler struct {
+	brain *brain.Brain
+	stop  chan struct{}
+
+	// Names of pods the scheduler never makes a decision about
+	ignoredPods map[string]bool
+	// Pods that do not fit preempt the lowest priority pod with priority lower than theirs
+	preempt bool
+
+	maxPods map[string]int64
+	// Map pod key to the name of the node the pod is assigned to
+	assignedPods map[string]string
+	// Map claim key (see model.PodKey) to whether the claim is bound to a volume
+	boundClaims map[string]bool
+}
+
+func newFakeScheduler(b *brain.Brain) *fakeScheduler {
+	return &fakeScheduler{
+		brain:       b,
+		stop:        make(chan struct{}),
+		ignoredPods: make(map[string]bool),
+	}
+}
+
+func (fs *fakeScheduler) run() {
+	for {
+		select {
+		case <-fs.stop:
+			return
+		default:
+			fs.listAndWatch()
+		}
+	}
+}
+
+func (fs *fakeScheduler) listAndWatch() {
+	fs.maxPods = make(map[string]int64)
+	fs.assignedPods = make(map[string]string)
+	fs.boundClaims = make(map[string]bool)
+
+	nodeList := fs.brain.GetNodes()
+	for _, node := range nodeList.Items {
+		fs.addNode(&node)
+	}
+	nodes, err := fs.brain.Watch("nodes", nodeList.ResourceVersion, "")
+	if err != nil {
+		return
+	}
+	defer nodes.Stop()
+
+	assignedList := fs.brain.GetPods(fieldselectors.AssignedNonTerminatedPods)
+	for _, pod := range assignedList.Items {
+		fs.assignedPods[model.PodKey(pod.Namespace, pod.Name)] = pod.Spec.NodeName
+	}
+	assigned, err := fs.brain.Watch("pods", assignedList.ResourceVersion, fieldselectors.AssignedNonTerminatedPods)
+	if err != nil {
+		return
+	}
+	defer assigned.Stop()
+
+	// Volumes are only watched, so that the simulator sees the scheduler synced
+	pvs, err := fs.brain.Watch("persistentvolumes", fs.brain.GetPvs().ResourceVersion, "")
+	if err != nil {
+		return
+	}
+	defer pvs.Stop()
+
+	claimList := fs.brain.GetPvcs()
+	for _, pvc := range claimList.Items {
+		fs.boundClaims[model.PodKey(pvc.Namespace, pvc.Name)] = pvc.Spec.VolumeName != ""
+	}
+	claims, err := fs.brain.Watch("persistentvolumeclaims", claimList.ResourceVersion, "")
+	if err != nil {
+		return
+	}
+	defer claims.Stop()
+
+	unassigned, err := fs.brain.Watch("pods", "0", fieldselectors.UnassignedNonTerminatedPods)
+	if err != nil {
+		return
+	}
+	defer unassigned.Stop()
+
+	for {
+		select {
+		case event, ok := <-nodes.ResultChan():
+			if !ok {
+				return
+			}
+			node := event.Object.(*v1.Node)
+			if event.Type == watch.Deleted {
+				delete(fs.maxPods, node.Name)
+			} else {
+				fs.addNode(node)
+			}
+		case event, ok := <-assigned.ResultChan():
+			if !ok {
+				return
+			}
+			pod := event.Object.(*v1.Pod)
+			if event.Type == watch.Deleted {
+				delete(fs.assignedPods, model.PodKey(pod.Namespace, pod.Name))
+			} else {
+				fs.assignedPods[model.PodKey(pod.Namespace, pod.Name)] = pod.Spec.NodeName
+			}
+		case _, ok := <-pvs.ResultChan():
+			if !ok {
+				return
+			}
+		case event, ok := <-claims.ResultChan():
+			if !ok {
+				return
+			}
+			pvc := event.Object.(*v1.PersistentVolumeClaim)
+			fs.boundClaims[model.PodKey(pvc.Namespace, pvc.Name)] = event.Type != watch.Deleted && pvc.Spec.VolumeName != ""
+		case event, ok := <-unassigned.ResultChan():
+			if !ok {
+				return
+			}
+			if event.Type == watch.Added {
+				fs.schedule(event.Object.(*v1.Pod))
+			}
+		case <-fs.stop:
+			return
+		}
+	}
+}
+
+// Unschedulable nodes are seen as full
+func (fs *fakeScheduler) addNode(node *v1.Node) {
+	maxPods := node.Status.Allocatable[v1.ResourcePods]
+	fs.maxPods[node.Name] = maxPods.Value()
+	if node.Spec.Unschedulable {
+		fs.maxPods[node.Name] = 0
+	}
+}
+
+func (fs *fakeScheduler) schedule(pod *v1.Pod) {
+	if fs.ignoredPods[pod.Name] {
+		return
+	}
+	if claim := fs.unboundClaim(pod); claim != "" {
+		fs.brain.Event(&v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+			Reason:         "FailedScheduling",
+			Message:        fmt.Sprintf("PersistentVolumeClaim is not bound: %q", claim),
+		})
+		return
+	}
+
+	podsOnNode := make(map[string]int64)
+	for _, nodeName := range fs.assignedPods {
+		podsOnNode[nodeName]++
+	}
+
+	var nodeNames []string
+	for nodeName := range fs.maxPods {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	chosenNode := ""
+	for _, nodeName := range nodeNames {
+		if podsOnNode[nodeName] >= fs.maxPods[nodeName] {
+			continue
+		}
+		if chosenNode == "" || podsOnNode[nodeName] < podsOnNode[chosenNode] {
+			chosenNode = nodeName
+		}
+	}
+
+	event := &v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+	}
+
+	if chosenNode == "" && fs.preempt {
+		if victim := fs.lowestPriorityVictim(pod); victim != nil {
+			fs.brain.NominatePod(pod.Namespace, pod.Name, victim.Spec.NodeName)
+			if err := fs.brain.EvictPod(victim.Namespace, victim.Name); err != nil {
+				return
+			}
+			delete(fs.assignedPods, model.PodKey(victim.Namespace, victim.Name))
+
+			event.Reason = "FailedScheduling"
+			event.Message = fmt.Sprintf("pod (%s) failed to fit in any node\n", pod.Name)
+			fs.brain.Event(event)
+
+			fs.schedule(pod)
+			return
+		}
+	}
+
+	if chosenNode == "" {
+		event.Reason = "FailedScheduling"
+		event.Message = fmt.Sprintf("pod (%s) failed to fit in any node\n", pod.Name)
+		for _, nodeName := range nodeNames {
+			event.Message += fmt.Sprintf("fit failure on node (%s): Insufficient pods\n", nodeName)
+		}
+	} else {
+		binding := &v1.Binding{
+			ObjectMeta: v1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+			Target:     v1.ObjectReference{Kind: "Node", Name: chosenNode},
+		}
+		if _, err := fs.brain.Binding(binding); err != nil {
+			return
+		}
+		fs.assignedPods[model.PodKey(pod.Namespace, pod.Name)] = chosenNode
+
+		event.Reason = "Scheduled"
+		event.Message = fmt.Sprintf("Successfully assigned %s to %s", pod.Name, chosenNode)
+	}
+
+	fs.brain.Event(event)
+}
+
+// Pods using PVCs that are not bound to a volume (or not known to the scheduler) do not fit any node
+func (fs *fakeScheduler) unboundClaim(pod *v1.Pod) string {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		if !fs.boundClaims[model.PodKey(pod.Namespace, volume.PersistentVolumeClaim.ClaimName)] {
+			return volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+
+	return ""
+}
+
+func (fs *fakeScheduler) lowestPriorityVictim(pod *v1.Pod) *v1.Pod {
+	var victim *v1.Pod
+	for _, assigned := range fs.brain.GetPods(fieldselectors.AssignedNonTerminatedPods).Items {
+		if podPriority(&assigned) >= podPriority(pod) {
+			continue
+		}
+		if victim == nil || podPriority(&assigned) < podPriority(victim) {
+			candidate := assigned
+			victim = &candidate
+		}
+	}
+
+	return victim
+}
+
+func podPriority(pod *v1.Pod) int32 {
+	if priority := model.PodPriority(pod); priority != nil {
+		return *priority
+	}
+
+	return 0
+}
+
+func newPodWithPriority(name, nodeName, priority string) v1.Pod {
+	pod := newPod(name, nodeName)
+	pod.Annotations = map[string]string{model.PriorityAnnotationKey: priority}
+	return pod
+}
+
+func freePort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Simulator of the fetched state with a running fake scheduler, which is configured by setup (if given)
+// before it starts. The returned function stops the scheduler.
+func newTestSimulation(t *testing.T, fetcher *fakeStateFetcher, setup func(fs *fakeScheduler)) (*Simulator, func()) {
+	clusterState, err := state.InitState(fetcher, nil)
+	assert.NoError(t, err)
+
+	eventChannel := make(chan *v1.Event)
+	errorChannel := make(chan error)
+	b := brain.New(clusterState, eventChannel, 1)
+	sh := schedulerHandler.New(b, freePort(t), errorChannel)
+	simulator := New(b, sh, eventChannel, errorChannel, []string{v1.DefaultSchedulerName}).(*Simulator)
+
+	scheduler := newFakeScheduler(b)
+	if setup != nil {
+		setup(scheduler)
+	}
+	go scheduler.run()
+
+	return simulator, func() { close(scheduler.stop) }
+}
+
+func TestSimulationResultsAreIdenticalAcrossRuns(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node-a", 2), newNode("node-b", 2)},
+		pods:  []v1.Pod{newPod("existing", "node-a")},
+	}
+	fetchState := func() (*state.ClusterState, error) {
+		return state.InitState(fetcher, nil)
+	}
+
+	s, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+	simulator := NewRefreshing(s, s.brain, fetchState)
+
+	expectedResults := []model.SchedulingResult{
+		{PodName: "default/first", Result: "Scheduled", Message: "Successfully assigned first to node-b", NodeName: "node-b"},
+		{PodName: "default/second", Result: "Scheduled", Message: "Successfully assigned second to node-a", NodeName: "node-a"},
+		{PodName: "default/third", Result: "Scheduled", Message: "Successfully assigned third to node-b", NodeName: "node-b"},
+		{
+			PodName: "default/fourth",
+			Result:  "FailedScheduling",
+			Message: "pod (fourth) failed to fit in any node\n" +
+				"fit failure on node (node-a): Insufficient pods\n" +
+				"fit failure on node (node-b): Insufficient pods\n",
+			FailedPredicates: map[string][]string{
+				"node-a": {"Insufficient pods"},
+				"node-b": {"Insufficient pods"},
+			},
+		},
+	}
+
+	for i := 0; i < 50; i++ {
+		var podsToCreate []*v1.Pod
+		for _, name := range []string{"first", "second", "third", "fourth"} {
+			podsToCreate = append(podsToCreate, &v1.Pod{ObjectMeta: v1.ObjectMeta{Name: name}})
+		}
+
+		response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nil, nil, time.Minute)
+
+		if assert.NoError(t, err) && assert.Len(t, response.Results, len(expectedResults)) {
+			for j, result := range response.Results {
+				assert.Equal(t, expectedResults[j], *result, "run %d", i)
+			}
+		}
+	}
+}
+
+func TestPodsWithoutDecisionTimeOut(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node", 10)},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, func(fs *fakeScheduler) { fs.ignoredPods["ignored"] = true })
+	defer stop()
+
+	podsToCreate := []*v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "scheduled"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "ignored"}},
+	}
+
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nil, nil, 200*time.Millisecond)
+
+	if assert.NoError(t, err) && assert.Len(t, response.Results, 2) {
+		assert.Equal(t, "Scheduled", response.Results[0].Result)
+		assert.Equal(t, "default/ignored", response.Results[1].PodName)
+		assert.Equal(t, model.TimedOutResult, response.Results[1].Result)
+	}
+}
+
+func TestPodsOfOtherSchedulersAreNotSimulated(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node", 10)},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+
+	podsToCreate := []*v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "default"}},
+		{ObjectMeta: v1.ObjectMeta{
+			Name:        "custom",
+			Annotations: map[string]string{model.SchedulerNameAnnotationKey: "gpu-scheduler"},
+		}},
+	}
+
+	start := time.Now()
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nil, nil, time.Minute)
+
+	assert.True(t, time.Since(start) < 10*time.Second)
+	if assert.NoError(t, err) && assert.Len(t, response.Results, 2) {
+		assert.Equal(t, "Scheduled", response.Results[0].Result)
+		assert.Equal(t, "default/custom", response.Results[1].PodName)
+		assert.Equal(t, model.UnsupportedSchedulerResult, response.Results[1].Result)
+		assert.Contains(t, response.Results[1].Message, "gpu-scheduler")
+	}
+	_, err = simulator.brain.GetPod("default", "custom")
+	assert.Error(t, err)
+}
+
+func TestPreemptedPodsReported(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node-a", 1), newNode("node-b", 1)},
+		pods:  []v1.Pod{newPodWithPriority("low", "node-a", "1"), newPodWithPriority("high", "node-b", "100")},
+		priorityClasses: []model.PriorityClass{
+			{ObjectMeta: v1.ObjectMeta{Name: "critical"}, Value: 1000},
+		},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, func(fs *fakeScheduler) { fs.preempt = true })
+	defer stop()
+
+	podsToCreate := []*v1.Pod{{ObjectMeta: v1.ObjectMeta{
+		Name:        "critical",
+		Annotations: map[string]string{model.PriorityClassNameAnnotationKey: "critical"},
+	}}}
+
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nil, nil, time.Minute)
+
+	if assert.NoError(t, err) && assert.Len(t, response.Results, 1) {
+		assert.Equal(t, "Scheduled", response.Results[0].Result)
+		assert.Equal(t, "node-a", response.Results[0].NodeName)
+		assert.Equal(t, "node-a", response.Results[0].NominatedNodeName)
+		assert.Equal(t, []string{"default/low"}, response.Results[0].PreemptedPods)
+	}
+	_, err = simulator.brain.GetPod(v1.NamespaceDefault, "low")
+	assert.Error(t, err)
+}
+
+func TestDrainedPodsRescheduled(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node-a", 2), newNode("node-b", 2)},
+		pods:  []v1.Pod{newPod("web-1", "node-a"), newPod("web-2", "node-a"), newPod("db", "node-b")},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+
+	response, err := simulator.RunMultiplePodSimulation(nil, nil, nil, &model.NodeMutations{Drain: []string{"node-a"}}, nil, time.Minute)
+
+	if assert.NoError(t, err) && assert.Len(t, response.Evicted, 2) {
+		assert.Empty(t, response.Results)
+		assert.Equal(t, "default/web-1", response.Evicted[0].PodName)
+		assert.Equal(t, "Scheduled", response.Evicted[0].Result)
+		assert.Equal(t, "node-b", response.Evicted[0].NodeName)
+		assert.Equal(t, "default/web-2", response.Evicted[1].PodName)
+		assert.Equal(t, "FailedScheduling", response.Evicted[1].Result)
+	}
+}
+
+func TestPodsScheduledOnAddedNodes(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node", 1)},
+		pods:  []v1.Pod{newPod("existing", "node")},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+
+	podsToCreate := []*v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "second"}},
+	}
+	nodeMutations := &model.NodeMutations{Add: []model.NodeTemplate{{CopyOf: "node", Count: 2}}}
+
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nodeMutations, nil, time.Minute)
+
+	if assert.NoError(t, err) && assert.Len(t, response.Results, 2) {
+		assert.Equal(t, "node-1", response.Results[0].NodeName)
+		assert.Equal(t, "node-2", response.Results[1].NodeName)
+		assert.Empty(t, response.Evicted)
+	}
+}
+
+func TestNodesEstimatedForFailedPods(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node", 1)},
+		pods:  []v1.Pod{newPod("existing", "node")},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+
+	podsToCreate := []*v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "first"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "second"}},
+	}
+	templates := []model.NodeTemplate{{CopyOf: "node", Count: 5}}
+
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nil, templates, time.Minute)
+
+	if assert.NoError(t, err) && assert.Len(t, response.Autoscaling, 1) {
+		assert.Equal(t, model.NodeEstimate{Template: "node", Nodes: 2, MaxNodes: 5}, response.Autoscaling[0])
+		for _, result := range response.Results {
+			assert.Equal(t, "FailedScheduling", result.Result)
+		}
+	}
+	// The state after the simulation is restored
+	assert.Len(t, simulator.brain.GetNodes().Items, 1)
+}
+
+func TestEstimationSharesSimulationDeadline(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node", 1)},
+		pods:  []v1.Pod{newPod("existing", "node")},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, func(fs *fakeScheduler) { fs.ignoredPods["ignored"] = true })
+	defer stop()
+
+	podsToCreate := []*v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "failed"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "ignored"}},
+	}
+	templates := []model.NodeTemplate{{CopyOf: "node", Count: 5}}
+
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nil, templates, 200*time.Millisecond)
+
+	// The simulation takes the whole timeout, so no time is left for the estimation
+	if assert.NoError(t, err) && assert.Len(t, response.Autoscaling, 1) {
+		assert.Equal(t, "FailedScheduling", response.Results[0].Result)
+		assert.Equal(t, model.TimedOutResult, response.Results[1].Result)
+		assert.Equal(t, model.NodeEstimate{
+			Template:      "node",
+			Nodes:         5,
+			MaxNodes:      5,
+			Unschedulable: []string{"default/failed"},
+			Message:       "estimation did not finish within 200ms",
+		}, response.Autoscaling[0])
+	}
+}
+
+func TestPodsOfPendingClaimsFailScheduling(t *testing.T) {
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node", 10)},
+		pvs: []v1.PersistentVolume{{
+			ObjectMeta: v1.ObjectMeta{Name: "pv"},
+			Spec: v1.PersistentVolumeSpec{
+				Capacity:    v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+				AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			},
+		}},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+
+	claims := []*v1.PersistentVolumeClaim{mocks.NewClaim("small", "5Gi"), mocks.NewClaim("big", "20Gi")}
+	podsToCreate := []*v1.Pod{newPodWithClaim("first", "small"), newPodWithClaim("second", "big")}
+
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, claims, nil, nil, time.Minute)
+
+	if assert.NoError(t, err) && assert.Len(t, response.Results, 2) {
+		assert.Equal(t, []model.ClaimResult{
+			{ClaimName: "default/small", Result: "Bound", VolumeName: "pv"},
+			{
+				ClaimName: "default/big",
+				Result:    "Pending",
+				Message:   "no persistent volume matches the claim and the claim has no storage class",
+			},
+		}, response.PersistentVolumeClaims)
+		assert.Equal(t, "Scheduled", response.Results[0].Result)
+		// Pods of pending claims are left to the scheduler, which does not find a node for them
+		assert.Equal(t, "FailedScheduling", response.Results[1].Result)
+	}
+}
+
+func newPodWithClaim(name, claimName string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: name},
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{{
+				Name: "data",
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+				},
+			}},
+		},
+	}
+}
+
+func TestResilienceReportsUnrecoveredWorkloads(t *testing.T) {
+	webPods := []v1.Pod{newPod("web-1", "node-a"), newPod("web-2", "node-a")}
+	for i := range webPods {
+		webPods[i].OwnerReferences = []v1.OwnerReference{{Kind: "ReplicaSet", Name: "web"}}
+	}
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node-a", 2), newNode("node-b", 3)},
+		pods:  append(webPods, newPod("db", "node-b")),
+	}
+	simulator, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+
+	response, err := simulator.RunResilienceAnalysis("", time.Minute)
+
+	if assert.NoError(t, err) && assert.Len(t, response.Domains, 2) {
+		nodeA, nodeB := response.Domains[0], response.Domains[1]
+		assert.Equal(t, "node-a", nodeA.Domain)
+		assert.Len(t, nodeA.Results, 2)
+		assert.Equal(t, []model.WorkloadResult{{
+			Kind:      "ReplicaSet",
+			Name:      "default/web",
+			Replicas:  2,
+			Scheduled: 2,
+			Message:   "2 of 2 lost replicas schedulable",
+		}}, nodeA.Workloads)
+		assert.Empty(t, nodeA.Unrecovered)
+
+		assert.Equal(t, "node-b", nodeB.Domain)
+		assert.Equal(t, []string{"Pod default/db"}, nodeB.Unrecovered)
+		assert.Equal(t, []string{"node-b"}, response.Unrecoverable)
+	}
+	// The state of the cluster is restored
+	assert.Len(t, simulator.brain.GetNodes().Items, 2)
+	assert.Len(t, simulator.brain.GetPods("").Items, 3)
+}
+
+func TestFailureDomainsGroupNodesByLabel(t *testing.T) {
+	nodes := []v1.Node{newNode("node-c", 1), newNode("node-a", 1), newNode("node-b", 1), newNode("master", 1)}
+	nodes[0].Labels = map[string]string{unversioned.LabelZoneFailureDomain: "zone-1"}
+	nodes[1].Labels = map[string]string{unversioned.LabelZoneFailureDomain: "zone-1"}
+	nodes[2].Labels = map[string]string{unversioned.LabelZoneFailureDomain: "zone-0"}
+
+	domains := failureDomains(nodes, unversioned.LabelZoneFailureDomain)
+
+	// Nodes without the label are domains of their own
+	assert.Equal(t, []model.DomainFailure{
+		{Domain: "master", Nodes: []string{"master"}, Unlabeled: true},
+		{Domain: "zone-0", Nodes: []string{"node-b"}},
+		{Domain: "zone-1", Nodes: []string{"node-a", "node-c"}},
+	}, domains)
+}
+
+func TestFailedPredicatesFromMessage(t *testing.T) {
+	message := "pod (pod) failed to fit in any node\n" +
+		"fit failure on node (node-1): Insufficient cpu, MatchNodeSelector\n" +
+		"fit failure on node (node-2): PodFitsHostPorts\n"
+
+	failedPredicates := failedPredicatesFromMessage(message)
+
+	assert.Equal(t, map[string][]string{
+		"node-1": {"Insufficient cpu", "MatchNodeSelector"},
+		"node-2": {"PodFitsHostPorts"},
+	}, failedPredicates)
+	assert.Nil(t, failedPredicatesFromMessage("no nodes available to schedule pods"))
+}
+
+func TestFailedPredicateCountsFromMessage(t *testing.T) {
+	// kube-scheduler 1.8 and later
+	counts := failedPredicateCountsFromMessage(
+		"0/3 nodes are available: 1 Insufficient cpu, 2 node(s) didn't match node selector.")
+	assert.Equal(t, map[string]int{"Insufficient cpu": 1, "node(s) didn't match node selector": 2}, counts)
+
+	// kube-scheduler 1.6 and 1.7
+	counts = failedPredicateCountsFromMessage(
+		"No nodes are available that match all of the following predicates:: Insufficient cpu (2), MatchNodeSelector (1).")
+	assert.Equal(t, map[string]int{"Insufficient cpu": 2, "MatchNodeSelector": 1}, counts)
+
+	assert.Nil(t, failedPredicateCountsFromMessage("no nodes available to schedule pods"))
+}
+
+func TestFailedPredicateCountsSetForNewSchedulers(t *testing.T) {
+	result := schedulingResultFromEvent(&v1.Event{
+		InvolvedObject: v1.ObjectReference{Namespace: "default", Name: "pod"},
+		Reason:         failedSchedulingReason,
+		Message:        "0/2 nodes are available: 2 Insufficient memory.",
+	})
+
+	assert.Nil(t, result.FailedPredicates)
+	assert.Equal(t, map[string]int{"Insufficient memory": 2}, result.FailedPredicateCounts)
+}
+
+func TestCapacityShowsRequestsBeforeAndAfterSimulation(t *testing.T) {
+	existingPod := newPod("existing", "node-a")
+	existingPod.Spec.Containers = []v1.Container{newContainer("100m", "1Gi")}
+	fetcher := &fakeStateFetcher{
+		nodes: []v1.Node{newNode("node-a", 10), newNode("node-b", 10)},
+		pods:  []v1.Pod{existingPod},
+	}
+	simulator, stop := newTestSimulation(t, fetcher, nil)
+	defer stop()
+
+	podsToCreate := []*v1.Pod{{
+		ObjectMeta: v1.ObjectMeta{Name: "new"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{newContainer("250m", "512Mi")}},
+	}}
+
+	response, err := simulator.RunMultiplePodSimulation(podsToCreate, nil, nil, nil, nil, time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.NodeCapacity{
+		{
+			NodeName:        "node-a",
+			Allocatable:     model.Resources{Pods: 10},
+			RequestedBefore: model.Resources{MilliCPU: 100, Memory: 1 << 30, Pods: 1},
+			RequestedAfter:  model.Resources{MilliCPU: 100, Memory: 1 << 30, Pods: 1},
+		},
+		{
+			NodeName:        "node-b",
+			Allocatable:     model.Resources{Pods: 10},
+			RequestedBefore: model.Resources{},
+			RequestedAfter:  model.Resources{MilliCPU: 250, Memory: 512 << 20, Pods: 1},
+		},
+	}, response.Capacity)
+}
+
+func newContainer(cpu, memory string) v1.Container {
+	return v1.Container{
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"k8s.io/client-go/1.5/pkg/api/v1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Returns allocatable resources of every node
+func (s *ClusterState) GetAllocatableResources() map[string]model.Resources {
+	s.RLock()
+	defer s.RUnlock()
+
+	allocatable := make(map[string]model.Resources, len(s.nodes))
+	for _, node := range s.nodes {
+		allocatable[node.Name] = model.Resources{
+			MilliCPU: milliCPU(node.Status.Allocatable),
+			Memory:   value(node.Status.Allocatable, v1.ResourceMemory),
+			Pods:     value(node.Status.Allocatable, v1.ResourcePods),
+		}
+	}
+
+	return allocatable
+}
+
+// Returns resources requested by pods running on every node, nodes without pods included
+func (s *ClusterState) GetRequestedResources() map[string]model.Resources {
+	s.RLock()
+	defer s.RUnlock()
+
+	requested := make(map[string]model.Resources, len(s.nodes))
+	for _, node := range s.nodes {
+		requested[node.Name] = model.Resources{}
+	}
+
+	for _, pod := range s.pods {
+		if !AssignedNonTerminatedPodFilter(&pod) {
+			continue
+		}
+
+		podRequest := podResourceRequest(&pod)
+		nodeRequested := requested[pod.Spec.NodeName]
+		nodeRequested.MilliCPU += podRequest.MilliCPU
+		nodeRequested.Memory += podRequest.Memory
+		nodeRequested.Pods++
+		requested[pod.Spec.NodeName] = nodeRequested
+	}
+
+	return requested
+}
+
+// Computes the request the same way as the scheduler does: init containers run one by one,
+// so the pod requests the sum of requests of its containers or the biggest init container request if that is higher.
+func podResourceRequest(pod *v1.Pod) model.Resources {
+	var result model.Resources
+
+	for _, container := range pod.Spec.Containers {
+		result.MilliCPU += milliCPU(container.Resources.Requests)
+		result.Memory += value(container.Resources.Requests, v1.ResourceMemory)
+	}
+
+	for _, container := range pod.Spec.InitContainers {
+		if cpu := milliCPU(container.Resources.Requests); cpu > result.MilliCPU {
+			result.MilliCPU = cpu
+		}
+		if memory := value(container.Resources.Requests, v1.ResourceMemory); memory > result.Memory {
+			result.Memory = memory
+		}
+	}
+
+	return result
+}
+
+func milliCPU(resources v1.ResourceList) int64 {
+	quantity := resources[v1.ResourceCPU]
+	return quantity.MilliValue()
+}
+
+func value(resources v1.ResourceList, name v1.ResourceName) int64 {
+	quantity := resources[name]
+	return quantity.Value()
+}
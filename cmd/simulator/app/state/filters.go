@@ -1,6 +1,10 @@
 package state
 
-import "k8s.io/client-go/1.5/pkg/api/v1"
+import (
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/fields"
+	"k8s.io/client-go/1.5/pkg/runtime"
+)
 
 type PodFilter func(pod *v1.Pod) bool
 
@@ -19,3 +23,20 @@ var UnassignedNonTerminatedPodFilter = func(pod *v1.Pod) bool {
 		pod.Status.Phase != v1.PodSucceeded &&
 		pod.Status.Phase != v1.PodFailed
 }
+
+// Matches pods the same way as the apiserver does for the given field selector
+func PodFieldSelectorFilter(selector fields.Selector) ObjectFilter {
+	return func(obj runtime.Object) bool {
+		pod, ok := obj.(*v1.Pod)
+		if !ok {
+			return false
+		}
+
+		return selector.Matches(fields.Set{
+			"metadata.name":      pod.Name,
+			"metadata.namespace": pod.Namespace,
+			"spec.nodeName":      pod.Spec.NodeName,
+			"status.phase":       string(pod.Status.Phase),
+		})
+	}
+}
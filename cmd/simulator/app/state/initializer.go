@@ -6,15 +6,19 @@ import (
 
 	"k8s.io/client-go/1.5/pkg/api"
 	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
 	"k8s.io/client-go/1.5/pkg/fields"
 
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/state/fieldselectors"
 	"github.com/Prytu/risk-advisor/pkg/kubeClient"
+	"github.com/Prytu/risk-advisor/pkg/model"
 )
 
-type InitStateFunc func(ksf kubeClient.ClusterStateFetcher) (*ClusterState, error)
+type InitStateFunc func(ksf kubeClient.ClusterStateFetcher, namespaces []string) (*ClusterState, error)
 
-func InitState(ksf kubeClient.ClusterStateFetcher) (*ClusterState, error) {
+// Fetches the state of the cluster. Namespaced objects are fetched from the given namespaces, or from all namespaces
+// if none are given.
+func InitState(ksf kubeClient.ClusterStateFetcher, namespaces []string) (*ClusterState, error) {
 	assignedSelector, err := convertFieldSelector(fieldselectors.AssignedNonTerminatedPods)
 	if err != nil {
 		return nil, err
@@ -25,9 +29,8 @@ func InitState(ksf kubeClient.ClusterStateFetcher) (*ClusterState, error) {
 		return nil, err
 	}
 
-	pvcs, err := ksf.GetPVCs("default")
-	if err != nil {
-		return nil, fmt.Errorf("error fetching PVCs: %s", err)
+	if len(namespaces) == 0 {
+		namespaces = []string{v1.NamespaceAll}
 	}
 
 	pvs, err := ksf.GetPVs()
@@ -35,42 +38,85 @@ func InitState(ksf kubeClient.ClusterStateFetcher) (*ClusterState, error) {
 		return nil, fmt.Errorf("error fetching PVs: %s", err)
 	}
 
-	replicasets, err := ksf.GetReplicaSets("default")
-	if err != nil {
-		return nil, fmt.Errorf("error fetching ReplicaSets: %s", err)
-	}
-
-	services, err := ksf.GetServices("default")
-	if err != nil {
-		return nil, fmt.Errorf("error fetching Services: %s", err)
-	}
-
-	replicationControllers, err := ksf.GetReplicationControllers("default")
-	if err != nil {
-		return nil, fmt.Errorf("error fetching Replication controllers: %s", err)
-	}
-
-	assignedPods, err := ksf.GetPods("default", assignedSelector)
+	nodeList, err := ksf.GetNodes()
 	if err != nil {
-		return nil, fmt.Errorf("error fetching Assigned Pods: %s", err)
+		return nil, fmt.Errorf("error fetching Nodes Pods: %s", err)
 	}
 
-	unassignedPods, err := ksf.GetPods("default", unassignedSelector)
+	priorityClasses, err := ksf.GetPriorityClasses()
 	if err != nil {
-		return nil, fmt.Errorf("error fetching Unassigned Pods: %s", err)
+		return nil, fmt.Errorf("error fetching PriorityClasses: %s", err)
 	}
 
-	nodeList, err := ksf.GetNodes()
+	storageClasses, err := ksf.GetStorageClasses()
 	if err != nil {
-		return nil, fmt.Errorf("error fetching Nodes Pods: %s", err)
+		return nil, fmt.Errorf("error fetching StorageClasses: %s", err)
 	}
 
-	podMap := make(map[string]v1.Pod, len(assignedPods.Items)+len(unassignedPods.Items))
-	for _, pod := range assignedPods.Items {
-		podMap[pod.Name] = pod
-	}
-	for _, pod := range unassignedPods.Items {
-		podMap[pod.Name] = pod
+	pvcs := &v1.PersistentVolumeClaimList{}
+	replicasets := &v1beta1.ReplicaSetList{}
+	services := &v1.ServiceList{}
+	replicationControllers := &v1.ReplicationControllerList{}
+	var statefulSets, podDisruptionBudgets []model.OpaqueObject
+	podMap := make(map[string]v1.Pod)
+
+	for _, namespace := range namespaces {
+		namespacePvcs, err := ksf.GetPVCs(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching PVCs: %s", err)
+		}
+		pvcs.Items = append(pvcs.Items, namespacePvcs.Items...)
+		pvcs.ListMeta = namespacePvcs.ListMeta
+
+		namespaceReplicasets, err := ksf.GetReplicaSets(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching ReplicaSets: %s", err)
+		}
+		replicasets.Items = append(replicasets.Items, namespaceReplicasets.Items...)
+		replicasets.ListMeta = namespaceReplicasets.ListMeta
+
+		namespaceServices, err := ksf.GetServices(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching Services: %s", err)
+		}
+		services.Items = append(services.Items, namespaceServices.Items...)
+		services.ListMeta = namespaceServices.ListMeta
+
+		namespaceReplicationControllers, err := ksf.GetReplicationControllers(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching Replication controllers: %s", err)
+		}
+		replicationControllers.Items = append(replicationControllers.Items, namespaceReplicationControllers.Items...)
+		replicationControllers.ListMeta = namespaceReplicationControllers.ListMeta
+
+		namespaceStatefulSets, err := ksf.GetStatefulSets(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching StatefulSets: %s", err)
+		}
+		statefulSets = append(statefulSets, namespaceStatefulSets...)
+
+		namespacePodDisruptionBudgets, err := ksf.GetPodDisruptionBudgets(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching PodDisruptionBudgets: %s", err)
+		}
+		podDisruptionBudgets = append(podDisruptionBudgets, namespacePodDisruptionBudgets...)
+
+		assignedPods, err := ksf.GetPods(namespace, assignedSelector)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching Assigned Pods: %s", err)
+		}
+
+		unassignedPods, err := ksf.GetPods(namespace, unassignedSelector)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching Unassigned Pods: %s", err)
+		}
+
+		for _, pod := range assignedPods.Items {
+			podMap[model.PodKey(pod.Namespace, pod.Name)] = pod
+		}
+		for _, pod := range unassignedPods.Items {
+			podMap[model.PodKey(pod.Namespace, pod.Name)] = pod
+		}
 	}
 
 	nodeMap := make(map[string]v1.Node, len(nodeList.Items))
@@ -85,6 +131,7 @@ func InitState(ksf kubeClient.ClusterStateFetcher) (*ClusterState, error) {
 
 	return &ClusterState{
 		resourceVersion:        resourceVersion,
+		historyStart:           resourceVersion,
 		pods:                   podMap,
 		nodes:                  nodeMap,
 		Pvcs:                   pvcs,
@@ -92,6 +139,10 @@ func InitState(ksf kubeClient.ClusterStateFetcher) (*ClusterState, error) {
 		Replicasets:            replicasets,
 		Services:               services,
 		ReplicationControllers: replicationControllers,
+		PriorityClasses:        priorityClasses,
+		StorageClasses:         storageClasses,
+		StatefulSets:           statefulSets,
+		PodDisruptionBudgets:   podDisruptionBudgets,
 	}, nil
 }
 
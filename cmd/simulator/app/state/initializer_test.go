@@ -0,0 +1,123 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/fields"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state/fieldselectors"
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// ClusterStateFetcher serving pods from a map of namespace to pods
+type fakeStateFetcher struct {
+	pods             map[string][]v1.Pod
+	podNamespaces    []string
+	assignedSelector string
+}
+
+func (f *fakeStateFetcher) GetPVCs(namespace string) (*v1.PersistentVolumeClaimList, error) {
+	return &v1.PersistentVolumeClaimList{}, nil
+}
+
+func (f *fakeStateFetcher) GetPVs() (*v1.PersistentVolumeList, error) {
+	return &v1.PersistentVolumeList{}, nil
+}
+
+func (f *fakeStateFetcher) GetReplicaSets(namespace string) (*v1beta1.ReplicaSetList, error) {
+	return &v1beta1.ReplicaSetList{}, nil
+}
+
+func (f *fakeStateFetcher) GetServices(namespace string) (*v1.ServiceList, error) {
+	return &v1.ServiceList{}, nil
+}
+
+func (f *fakeStateFetcher) GetReplicationControllers(namespace string) (*v1.ReplicationControllerList, error) {
+	return &v1.ReplicationControllerList{}, nil
+}
+
+func (f *fakeStateFetcher) GetPods(namespace string, fieldSelector fields.Selector) (*v1.PodList, error) {
+	// Serve every pod once, as an assigned pod
+	if fieldSelector.String() != f.assignedSelector {
+		return &v1.PodList{}, nil
+	}
+
+	f.podNamespaces = append(f.podNamespaces, namespace)
+	if namespace == v1.NamespaceAll {
+		var pods []v1.Pod
+		for _, namespacePods := range f.pods {
+			pods = append(pods, namespacePods...)
+		}
+		return &v1.PodList{Items: pods}, nil
+	}
+
+	return &v1.PodList{Items: f.pods[namespace]}, nil
+}
+
+func (f *fakeStateFetcher) GetNodes() (*v1.NodeList, error) {
+	return &v1.NodeList{ListMeta: unversioned.ListMeta{ResourceVersion: "1"}}, nil
+}
+
+func (f *fakeStateFetcher) GetPriorityClasses() ([]model.PriorityClass, error) {
+	return nil, nil
+}
+
+func (f *fakeStateFetcher) GetStorageClasses() ([]model.StorageClass, error) {
+	return nil, nil
+}
+
+func (f *fakeStateFetcher) GetStatefulSets(namespace string) ([]model.OpaqueObject, error) {
+	return nil, nil
+}
+
+func (f *fakeStateFetcher) GetPodDisruptionBudgets(namespace string) ([]model.OpaqueObject, error) {
+	return nil, nil
+}
+
+func newFakeStateFetcher(t *testing.T) *fakeStateFetcher {
+	assignedSelector, err := convertFieldSelector(fieldselectors.AssignedNonTerminatedPods)
+	assert.NoError(t, err)
+
+	return &fakeStateFetcher{
+		pods: map[string][]v1.Pod{
+			"default": {newPod("default", "pod")},
+			"team":    {newPod("team", "pod")},
+		},
+		assignedSelector: assignedSelector.String(),
+	}
+}
+
+func newPod(namespace, name string) v1.Pod {
+	return v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func TestInitStateFetchesAllNamespaces(t *testing.T) {
+	fetcher := newFakeStateFetcher(t)
+
+	state, err := InitState(fetcher, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{v1.NamespaceAll}, fetcher.podNamespaces)
+	assert.Len(t, state.GetPods(AllPodsFilter), 2)
+	_, ok := state.GetPod("default", "pod")
+	assert.True(t, ok)
+	_, ok = state.GetPod("team", "pod")
+	assert.True(t, ok)
+}
+
+func TestInitStateFetchesGivenNamespaces(t *testing.T) {
+	fetcher := newFakeStateFetcher(t)
+
+	state, err := InitState(fetcher, []string{"team"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team"}, fetcher.podNamespaces)
+	assert.Len(t, state.GetPods(AllPodsFilter), 1)
+	_, ok := state.GetPod("default", "pod")
+	assert.False(t, ok)
+}
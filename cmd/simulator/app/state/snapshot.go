@@ -0,0 +1,63 @@
+package state
+
+import (
+	"sort"
+	"strconv"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Returns a copy of the current state, which can be saved and used to initialize the state again
+func (s *ClusterState) Snapshot() *model.Snapshot {
+	s.RLock()
+	defer s.RUnlock()
+
+	snapshot := &model.Snapshot{
+		ResourceVersion: strconv.FormatInt(s.resourceVersion, 10),
+		Nodes:           make([]v1.Node, 0, len(s.nodes)),
+		Pods:            make([]v1.Pod, 0, len(s.pods)),
+	}
+
+	// Objects are sorted, so that snapshots of the same state are the same
+	nodeNames := make([]string, 0, len(s.nodes))
+	for nodeName := range s.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		snapshot.Nodes = append(snapshot.Nodes, s.nodes[nodeName])
+	}
+
+	podKeys := make([]string, 0, len(s.pods))
+	for podKey := range s.pods {
+		podKeys = append(podKeys, podKey)
+	}
+	sort.Strings(podKeys)
+	for _, podKey := range podKeys {
+		snapshot.Pods = append(snapshot.Pods, s.pods[podKey])
+	}
+	if s.Pvs != nil {
+		snapshot.PersistentVolumes = append([]v1.PersistentVolume(nil), s.Pvs.Items...)
+	}
+	if s.Pvcs != nil {
+		snapshot.PersistentVolumeClaims = append([]v1.PersistentVolumeClaim(nil), s.Pvcs.Items...)
+	}
+	if s.Replicasets != nil {
+		snapshot.ReplicaSets = append([]v1beta1.ReplicaSet(nil), s.Replicasets.Items...)
+	}
+	if s.Services != nil {
+		snapshot.Services = append([]v1.Service(nil), s.Services.Items...)
+	}
+	if s.ReplicationControllers != nil {
+		snapshot.ReplicationControllers = append([]v1.ReplicationController(nil), s.ReplicationControllers.Items...)
+	}
+	snapshot.PriorityClasses = append([]model.PriorityClass(nil), s.PriorityClasses...)
+	snapshot.StorageClasses = append([]model.StorageClass(nil), s.StorageClasses...)
+	snapshot.StatefulSets = append([]model.OpaqueObject(nil), s.StatefulSets...)
+	snapshot.PodDisruptionBudgets = append([]model.OpaqueObject(nil), s.PodDisruptionBudgets...)
+
+	return snapshot
+}
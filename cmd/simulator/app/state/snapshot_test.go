@@ -0,0 +1,27 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Prytu/risk-advisor/pkg/kubeClient"
+)
+
+func TestStateInitializedFromSnapshotIsTheSame(t *testing.T) {
+	state, err := InitState(newFakeStateFetcher(t), nil)
+	assert.NoError(t, err)
+	pod := newPod("team", "scheduled")
+	pod.Spec.NodeName = "node"
+	state.AddPod(pod)
+
+	snapshot := state.Snapshot()
+	replayedState, err := InitState(kubeClient.NewSnapshotFetcher(snapshot), nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, replayedState.Snapshot())
+	if assert.Len(t, snapshot.Pods, 3) {
+		assert.Equal(t, "default", snapshot.Pods[0].Namespace)
+		assert.Equal(t, "scheduled", snapshot.Pods[2].Name)
+	}
+}
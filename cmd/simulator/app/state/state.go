@@ -2,16 +2,25 @@ package state
 
 import (
 	"fmt"
+	"strconv"
 	"sync"
 
 	"k8s.io/client-go/1.5/pkg/api/v1"
 	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
 )
 
 type ClusterState struct {
 	sync.RWMutex
 	resourceVersion int64
 
+	// Changes of objects after historyStart resource version, used by watchers
+	history      []*objectChange
+	historyStart int64
+	watchers     []*Watcher
+
+	// Pods are identified by namespace and name, see model.PodKey
 	pods                   map[string]v1.Pod
 	nodes                  map[string]v1.Node
 	Pvcs                   *v1.PersistentVolumeClaimList
@@ -19,14 +28,58 @@ type ClusterState struct {
 	Replicasets            *v1beta1.ReplicaSetList
 	Services               *v1.ServiceList
 	ReplicationControllers *v1.ReplicationControllerList
+	PriorityClasses        []model.PriorityClass
+	StorageClasses         []model.StorageClass
+	StatefulSets           []model.OpaqueObject
+	PodDisruptionBudgets   []model.OpaqueObject
+}
+
+// Replaces whole content of the state with a fresh snapshot, e.g. before the next simulation.
+// Resource version never decreases, so clients can tell the new state from the old one.
+func (s *ClusterState) Reset(fresh *ClusterState) {
+	s.Lock()
+	defer s.Unlock()
+
+	fresh.RLock()
+	defer fresh.RUnlock()
+
+	if fresh.resourceVersion > s.resourceVersion {
+		s.resourceVersion = fresh.resourceVersion
+	} else {
+		s.resourceVersion++
+	}
+
+	s.pods = fresh.pods
+	s.nodes = fresh.nodes
+	s.Pvcs = fresh.Pvcs
+	s.Pvs = fresh.Pvs
+	s.Replicasets = fresh.Replicasets
+	s.Services = fresh.Services
+	s.ReplicationControllers = fresh.ReplicationControllers
+	s.PriorityClasses = fresh.PriorityClasses
+	s.StorageClasses = fresh.StorageClasses
+	s.StatefulSets = fresh.StatefulSets
+	s.PodDisruptionBudgets = fresh.PodDisruptionBudgets
+
+	s.resetWatchers()
 }
 
-func (s *ClusterState) AddPod(pod v1.Pod) {
+// Adds a new pod to the state, returns false if there already is a pod with the same namespace and name.
+func (s *ClusterState) AddPod(pod v1.Pod) bool {
 	s.Lock()
 	defer s.Unlock()
 
+	key := model.PodKey(pod.Namespace, pod.Name)
+	if _, ok := s.pods[key]; ok {
+		return false
+	}
+
 	s.resourceVersion++
-	s.pods[pod.Name] = pod
+	pod.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	s.pods[key] = pod
+	s.recordChange("pods", nil, typedPod(pod))
+
+	return true
 }
 
 func (s *ClusterState) GetResourceVersion() int64 {
@@ -50,6 +103,34 @@ func (s *ClusterState) GetPods(filter PodFilter) []v1.Pod {
 	return pods
 }
 
+// Returns pods matching the filter together with the resource version of the state they come from.
+func (s *ClusterState) ListPods(filter PodFilter) ([]v1.Pod, int64) {
+	s.RLock()
+	defer s.RUnlock()
+
+	pods := make([]v1.Pod, 0)
+	for _, pod := range s.pods {
+		if filter(&pod) {
+			pods = append(pods, pod)
+		}
+	}
+
+	return pods, s.resourceVersion
+}
+
+// Returns all nodes together with the resource version of the state they come from.
+func (s *ClusterState) ListNodes() ([]v1.Node, int64) {
+	s.RLock()
+	defer s.RUnlock()
+
+	nodes := make([]v1.Node, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node)
+	}
+
+	return nodes, s.resourceVersion
+}
+
 func (s *ClusterState) GetNodes() []v1.Node {
 	s.RLock()
 	defer s.RUnlock()
@@ -64,11 +145,155 @@ func (s *ClusterState) GetNodes() []v1.Node {
 	return nodes
 }
 
-func (s *ClusterState) GetPod(name string) (v1.Pod, bool) {
+// Lists below are returned with the resource version of the state, so that watches started from it are valid.
+func (s *ClusterState) GetPvcs() *v1.PersistentVolumeClaimList {
 	s.RLock()
 	defer s.RUnlock()
 
-	pod, ok := s.pods[name]
+	pvcs := *s.Pvcs
+	pvcs.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	return &pvcs
+}
+
+func (s *ClusterState) GetPvs() *v1.PersistentVolumeList {
+	s.RLock()
+	defer s.RUnlock()
+
+	pvs := *s.Pvs
+	pvs.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	return &pvs
+}
+
+func (s *ClusterState) GetReplicasets() *v1beta1.ReplicaSetList {
+	s.RLock()
+	defer s.RUnlock()
+
+	replicasets := *s.Replicasets
+	replicasets.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	return &replicasets
+}
+
+func (s *ClusterState) GetServices() *v1.ServiceList {
+	s.RLock()
+	defer s.RUnlock()
+
+	services := *s.Services
+	services.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	return &services
+}
+
+func (s *ClusterState) GetReplicationControllers() *v1.ReplicationControllerList {
+	s.RLock()
+	defer s.RUnlock()
+
+	replicationControllers := *s.ReplicationControllers
+	replicationControllers.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	return &replicationControllers
+}
+
+// Priority classes never change during a simulation, so they are served without resource version
+func (s *ClusterState) GetPriorityClasses() []model.PriorityClass {
+	s.RLock()
+	defer s.RUnlock()
+
+	return append([]model.PriorityClass(nil), s.PriorityClasses...)
+}
+
+// Storage classes never change during a simulation, so they are served without resource version
+func (s *ClusterState) GetStorageClasses() []model.StorageClass {
+	s.RLock()
+	defer s.RUnlock()
+
+	return append([]model.StorageClass(nil), s.StorageClasses...)
+}
+
+// StatefulSets and PodDisruptionBudgets never change during a simulation either
+func (s *ClusterState) GetStatefulSets() []model.OpaqueObject {
+	s.RLock()
+	defer s.RUnlock()
+
+	return append([]model.OpaqueObject(nil), s.StatefulSets...)
+}
+
+func (s *ClusterState) GetPodDisruptionBudgets() []model.OpaqueObject {
+	s.RLock()
+	defer s.RUnlock()
+
+	return append([]model.OpaqueObject(nil), s.PodDisruptionBudgets...)
+}
+
+// Adds a new PVC to the state, returns false if there already is a PVC with the same namespace and name.
+// Lists of PVCs and PVs are shared with snapshots of the state, so they are copied on every change.
+func (s *ClusterState) AddPvc(pvc v1.PersistentVolumeClaim) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, existing := range s.Pvcs.Items {
+		if existing.Namespace == pvc.Namespace && existing.Name == pvc.Name {
+			return false
+		}
+	}
+
+	s.resourceVersion++
+	pvc.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	pvcs := *s.Pvcs
+	pvcs.Items = append(append([]v1.PersistentVolumeClaim(nil), s.Pvcs.Items...), pvc)
+	s.Pvcs = &pvcs
+	s.recordChange("persistentvolumeclaims", nil, typedPvc(pvc))
+
+	return true
+}
+
+// Adds a new PV to the state, returns false if there already is a PV with the same name.
+func (s *ClusterState) AddPv(pv v1.PersistentVolume) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, existing := range s.Pvs.Items {
+		if existing.Name == pv.Name {
+			return false
+		}
+	}
+
+	s.resourceVersion++
+	pv.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	pvs := *s.Pvs
+	pvs.Items = append(append([]v1.PersistentVolume(nil), s.Pvs.Items...), pv)
+	s.Pvs = &pvs
+	s.recordChange("persistentvolumes", nil, typedPv(pv))
+
+	return true
+}
+
+// Replaces the PV with the same name, returns false if there is no such PV.
+func (s *ClusterState) UpdatePv(pv v1.PersistentVolume) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for i, existing := range s.Pvs.Items {
+		if existing.Name != pv.Name {
+			continue
+		}
+
+		s.resourceVersion++
+		pv.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+		pvs := *s.Pvs
+		pvs.Items = append([]v1.PersistentVolume(nil), s.Pvs.Items...)
+		pvs.Items[i] = pv
+		s.Pvs = &pvs
+		s.recordChange("persistentvolumes", typedPv(existing), typedPv(pv))
+
+		return true
+	}
+
+	return false
+}
+
+func (s *ClusterState) GetPod(namespace, name string) (v1.Pod, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	pod, ok := s.pods[model.PodKey(namespace, name)]
 	if !ok {
 		return v1.Pod{}, false
 	}
@@ -76,16 +301,97 @@ func (s *ClusterState) GetPod(name string) (v1.Pod, bool) {
 	return pod, ok
 }
 
-func (s *ClusterState) UpdatePod(podName string, newPodState v1.Pod) {
+func (s *ClusterState) UpdatePod(newPodState v1.Pod) {
 	s.Lock()
 	defer s.Unlock()
 
 	s.resourceVersion++
 
-	if _, ok := s.pods[podName]; !ok {
+	key := model.PodKey(newPodState.Namespace, newPodState.Name)
+	oldPodState, ok := s.pods[key]
+	if !ok {
 		// TODO: Find out if such situation can happen in our simulation. If yes - fix this one
-		panic(fmt.Sprintf("ClusterState UpdatePod error: trying to update a pod with name %s which does not exist!", podName))
+		panic(fmt.Sprintf("ClusterState UpdatePod error: trying to update a pod %s which does not exist!", key))
+	}
+
+	newPodState.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	s.pods[key] = newPodState
+	s.recordChange("pods", typedPod(oldPodState), typedPod(newPodState))
+}
+
+func (s *ClusterState) DeletePod(namespace, name string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	key := model.PodKey(namespace, name)
+	pod, ok := s.pods[key]
+	if !ok {
+		return false
+	}
+
+	s.resourceVersion++
+	delete(s.pods, key)
+	s.recordChange("pods", typedPod(pod), nil)
+
+	return true
+}
+
+func (s *ClusterState) GetNode(name string) (v1.Node, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	node, ok := s.nodes[name]
+	return node, ok
+}
+
+// Adds a new node to the state, returns false if there already is a node with the same name.
+func (s *ClusterState) AddNode(node v1.Node) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.nodes[node.Name]; ok {
+		return false
+	}
+
+	s.resourceVersion++
+	node.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	s.nodes[node.Name] = node
+	s.recordChange("nodes", nil, typedNode(node))
+
+	return true
+}
+
+// Replaces the node with the same name, returns false if there is no such node.
+func (s *ClusterState) UpdateNode(node v1.Node) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	oldNode, ok := s.nodes[node.Name]
+	if !ok {
+		return false
 	}
 
-	s.pods[podName] = newPodState
+	s.resourceVersion++
+	node.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+	s.nodes[node.Name] = node
+	s.recordChange("nodes", typedNode(oldNode), typedNode(node))
+
+	return true
+}
+
+// Removes the node, pods assigned to it are not removed.
+func (s *ClusterState) DeleteNode(name string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	node, ok := s.nodes[name]
+	if !ok {
+		return false
+	}
+
+	s.resourceVersion++
+	delete(s.nodes, name)
+	s.recordChange("nodes", typedNode(node), nil)
+
+	return true
 }
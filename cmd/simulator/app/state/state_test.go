@@ -0,0 +1,43 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+func newEmptyState() *ClusterState {
+	return &ClusterState{
+		pods:  make(map[string]v1.Pod),
+		nodes: make(map[string]v1.Node),
+	}
+}
+
+func TestPodsWithSameNameInDifferentNamespaces(t *testing.T) {
+	state := newEmptyState()
+
+	assert.True(t, state.AddPod(newPod("default", "pod")))
+	assert.True(t, state.AddPod(newPod("team", "pod")))
+
+	assert.Len(t, state.GetPods(AllPodsFilter), 2)
+
+	assert.True(t, state.DeletePod("team", "pod"))
+	_, ok := state.GetPod("default", "pod")
+	assert.True(t, ok)
+	_, ok = state.GetPod("team", "pod")
+	assert.False(t, ok)
+}
+
+func TestAddingExistingPodDoesNotReplaceIt(t *testing.T) {
+	state := newEmptyState()
+	existingPod := newPod("default", "pod")
+	existingPod.Spec.NodeName = "node"
+	state.AddPod(existingPod)
+
+	assert.False(t, state.AddPod(newPod("default", "pod")))
+
+	pod, _ := state.GetPod("default", "pod")
+	assert.Equal(t, "node", pod.Spec.NodeName)
+}
@@ -0,0 +1,250 @@
+package state
+
+import (
+	"errors"
+	"strconv"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/pkg/watch"
+)
+
+// Maximum number of changes kept for watchers that start watching from an older resource version
+const maxHistoryLength = 10000
+
+// Size of the event buffer of every watcher. Watchers that do not keep up are stopped.
+const watcherBufferSize = 1000
+
+var ErrResourceVersionTooOld = errors.New("too old resource version")
+
+type ObjectFilter func(obj runtime.Object) bool
+
+var AllObjectsFilter = func(obj runtime.Object) bool {
+	return true
+}
+
+// Single change of an object kept in the state. oldObject is nil for added objects,
+// newObject is nil for deleted objects.
+type objectChange struct {
+	resourceVersion int64
+	resource        string
+	oldObject       runtime.Object
+	newObject       runtime.Object
+}
+
+// Watcher receives events about changes of objects of a single resource, like the apiserver watch does.
+type Watcher struct {
+	resource string
+	filter   ObjectFilter
+	state    *ClusterState
+	events   chan watch.Event
+	stopped  bool
+}
+
+func (w *Watcher) ResultChan() <-chan watch.Event {
+	return w.events
+}
+
+func (w *Watcher) Stop() {
+	w.state.Lock()
+	defer w.state.Unlock()
+
+	w.state.removeWatcher(w)
+}
+
+// Starts watching changes of the resource that happened after given resource version. Watching from resource
+// version 0 starts with ADDED events for all current objects, like in the apiserver.
+func (s *ClusterState) Watch(resource string, resourceVersion int64, filter ObjectFilter) (*Watcher, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	w := &Watcher{
+		resource: resource,
+		filter:   filter,
+		state:    s,
+		events:   make(chan watch.Event, watcherBufferSize),
+	}
+
+	if resourceVersion == 0 {
+		for _, obj := range s.currentObjects(resource) {
+			w.send(&objectChange{resourceVersion: s.resourceVersion, resource: resource, newObject: obj})
+		}
+	} else {
+		if resourceVersion < s.historyStart || resourceVersion > s.resourceVersion {
+			return nil, ErrResourceVersionTooOld
+		}
+
+		for _, change := range s.history {
+			if change.resourceVersion > resourceVersion {
+				w.send(change)
+			}
+		}
+	}
+
+	if !w.stopped {
+		s.watchers = append(s.watchers, w)
+	}
+
+	return w, nil
+}
+
+// Must be called with the state locked.
+func (s *ClusterState) recordChange(resource string, oldObject, newObject runtime.Object) {
+	change := &objectChange{
+		resourceVersion: s.resourceVersion,
+		resource:        resource,
+		oldObject:       oldObject,
+		newObject:       newObject,
+	}
+
+	s.history = append(s.history, change)
+	if len(s.history) > maxHistoryLength {
+		s.history = s.history[1:]
+		s.historyStart = s.history[0].resourceVersion - 1
+	}
+
+	// Watchers that do not keep up are removed while sending, so iterate over a copy
+	for _, w := range append([]*Watcher(nil), s.watchers...) {
+		w.send(change)
+	}
+}
+
+// Stops all watchers and forgets all changes, so clients have to list the objects again to get the current state.
+func (s *ClusterState) ResetWatchers() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.resetWatchers()
+}
+
+// Must be called with the state locked.
+func (s *ClusterState) resetWatchers() {
+	s.history = nil
+	s.historyStart = s.resourceVersion
+
+	for len(s.watchers) > 0 {
+		s.removeWatcher(s.watchers[0])
+	}
+}
+
+// Must be called with the state locked.
+func (s *ClusterState) removeWatcher(w *Watcher) {
+	if w.stopped {
+		return
+	}
+
+	w.stopped = true
+	close(w.events)
+
+	for i, watcher := range s.watchers {
+		if watcher == w {
+			s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Must be called with the state locked.
+func (s *ClusterState) currentObjects(resource string) []runtime.Object {
+	var objects []runtime.Object
+
+	switch resource {
+	case "pods":
+		for _, pod := range s.pods {
+			objects = append(objects, typedPod(pod))
+		}
+	case "nodes":
+		for _, node := range s.nodes {
+			objects = append(objects, typedNode(node))
+		}
+	case "persistentvolumeclaims":
+		for _, pvc := range s.Pvcs.Items {
+			objects = append(objects, typedPvc(pvc))
+		}
+	case "persistentvolumes":
+		for _, pv := range s.Pvs.Items {
+			objects = append(objects, typedPv(pv))
+		}
+	}
+
+	return objects
+}
+
+// Translates the change into an event as seen by the watcher. Objects that stop (or start) matching
+// watcher's filter are seen as deleted (or added).
+func (w *Watcher) send(change *objectChange) {
+	if w.stopped || change.resource != w.resource {
+		return
+	}
+
+	oldMatches := change.oldObject != nil && w.filter(change.oldObject)
+	newMatches := change.newObject != nil && w.filter(change.newObject)
+
+	var event watch.Event
+	switch {
+	case oldMatches && newMatches:
+		event = watch.Event{Type: watch.Modified, Object: change.newObject}
+	case newMatches:
+		event = watch.Event{Type: watch.Added, Object: change.newObject}
+	case oldMatches && change.newObject != nil:
+		event = watch.Event{Type: watch.Deleted, Object: change.newObject}
+	case oldMatches:
+		event = watch.Event{Type: watch.Deleted, Object: withResourceVersion(change.oldObject, change.resourceVersion)}
+	default:
+		return
+	}
+
+	select {
+	case w.events <- event:
+	default:
+		// Watcher does not keep up, stop it so the client lists the objects again
+		w.state.removeWatcher(w)
+	}
+}
+
+func withResourceVersion(obj runtime.Object, resourceVersion int64) runtime.Object {
+	rv := strconv.FormatInt(resourceVersion, 10)
+
+	switch typed := obj.(type) {
+	case *v1.Pod:
+		objCopy := *typed
+		objCopy.ResourceVersion = rv
+		return &objCopy
+	case *v1.Node:
+		objCopy := *typed
+		objCopy.ResourceVersion = rv
+		return &objCopy
+	case *v1.PersistentVolumeClaim:
+		objCopy := *typed
+		objCopy.ResourceVersion = rv
+		return &objCopy
+	case *v1.PersistentVolume:
+		objCopy := *typed
+		objCopy.ResourceVersion = rv
+		return &objCopy
+	}
+
+	return obj
+}
+
+// Objects sent in watch events have to carry their kind, so that clients can decode them
+func typedPod(pod v1.Pod) *v1.Pod {
+	pod.TypeMeta = unversioned.TypeMeta{Kind: "Pod", APIVersion: "v1"}
+	return &pod
+}
+
+func typedNode(node v1.Node) *v1.Node {
+	node.TypeMeta = unversioned.TypeMeta{Kind: "Node", APIVersion: "v1"}
+	return &node
+}
+
+func typedPvc(pvc v1.PersistentVolumeClaim) *v1.PersistentVolumeClaim {
+	pvc.TypeMeta = unversioned.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"}
+	return &pvc
+}
+
+func typedPv(pv v1.PersistentVolume) *v1.PersistentVolume {
+	pv.TypeMeta = unversioned.TypeMeta{Kind: "PersistentVolume", APIVersion: "v1"}
+	return &pv
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/fields"
+	"k8s.io/client-go/1.5/pkg/watch"
+
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/state/fieldselectors"
+)
+
+func podWatchFilter(t *testing.T, selector string) ObjectFilter {
+	parsed, err := fields.ParseSelector(selector)
+	assert.NoError(t, err)
+
+	return PodFieldSelectorFilter(parsed)
+}
+
+func receiveEvent(t *testing.T, w *Watcher) watch.Event {
+	select {
+	case event := <-w.ResultChan():
+		return event
+	default:
+		t.Fatal("expected watch event")
+		return watch.Event{}
+	}
+}
+
+func TestWatchersSeeBindingAsMoveBetweenFilters(t *testing.T) {
+	state := newEmptyState()
+	rv := state.GetResourceVersion()
+
+	unassigned, err := state.Watch("pods", rv, podWatchFilter(t, fieldselectors.UnassignedNonTerminatedPods))
+	assert.NoError(t, err)
+	assigned, err := state.Watch("pods", rv, podWatchFilter(t, fieldselectors.AssignedNonTerminatedPods))
+	assert.NoError(t, err)
+
+	pod := newPod("default", "pod")
+	state.AddPod(pod)
+
+	event := receiveEvent(t, unassigned)
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "Pod", event.Object.(*v1.Pod).Kind)
+
+	pod.Spec.NodeName = "node"
+	state.UpdatePod(pod)
+
+	event = receiveEvent(t, unassigned)
+	assert.Equal(t, watch.Deleted, event.Type)
+	event = receiveEvent(t, assigned)
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "node", event.Object.(*v1.Pod).Spec.NodeName)
+	assert.Equal(t, "2", event.Object.(*v1.Pod).ResourceVersion)
+}
+
+func TestWatchReplaysChangesAfterResourceVersion(t *testing.T) {
+	state := newEmptyState()
+	state.AddPod(newPod("default", "first"))
+	rv := state.GetResourceVersion()
+	state.AddPod(newPod("default", "second"))
+
+	w, err := state.Watch("pods", rv, AllObjectsFilter)
+	assert.NoError(t, err)
+
+	event := receiveEvent(t, w)
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "second", event.Object.(*v1.Pod).Name)
+}
+
+func TestWatchFromResourceVersionBeforeResetFails(t *testing.T) {
+	state := newEmptyState()
+	state.AddPod(newPod("default", "pod"))
+	rv := state.GetResourceVersion()
+	w, err := state.Watch("pods", rv, AllObjectsFilter)
+	assert.NoError(t, err)
+
+	state.Reset(newEmptyState())
+
+	_, ok := <-w.ResultChan()
+	assert.False(t, ok)
+	_, err = state.Watch("pods", rv, AllObjectsFilter)
+	assert.Equal(t, ErrResourceVersionTooOld, err)
+}
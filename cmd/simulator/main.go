@@ -3,11 +3,19 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"k8s.io/client-go/1.5/pkg/api/v1"
 
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/initializer"
+	"github.com/Prytu/risk-advisor/cmd/simulator/app/localScheduler"
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/riskadvisorHandler"
 	"github.com/Prytu/risk-advisor/cmd/simulator/app/state"
 	"github.com/Prytu/risk-advisor/pkg/flags"
@@ -16,22 +24,83 @@ import (
 
 func main() {
 	raCommunicationPort := flag.String("ra-port", defaults.RACommunicationPort, "Port for communictaion with risk-advisor")
+	address := flag.String("address", "", "IP address on which the simulator listens for requests, all interfaces by default")
 	schedulerCommunicationPort := flag.String("scheduler-port", defaults.SchedulerCommunicationPort, "Port for communication with scheduler")
+	namespaces := flag.String("namespaces", "", "Comma separated list of namespaces included in the cluster snapshot, all namespaces by default")
+	longLived := flag.Bool("long-lived", false, "Serve many requests, fetching the cluster state again before each of them")
+	simulationTimeout := flag.Int("simulation-timeout", defaults.SimulationTimeout, "Default maximum duration in seconds of a simulation, pods not scheduled by then get TimedOut results")
+	snapshotFile := flag.String("snapshot", "", "Read the cluster state from a snapshot file instead of the cluster")
+	schedulerBinary := flag.String("scheduler-binary", "", "Start kube-scheduler binary as a child process, e.g. to simulate without a cluster")
+	schedulerPolicyFile := flag.String("scheduler-policy-file", "", "Scheduler policy file used by the scheduler started with --scheduler-binary")
+	schedulers := flag.String("schedulers", v1.DefaultSchedulerName, "Comma separated names of schedulers talking to the simulator, pods of other schedulers get UnsupportedScheduler results")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file, used instead of the in-cluster configuration")
+	context := flag.String("context", "", "Name of the kubeconfig context to use")
+	schedulerArgs := flag.String("scheduler-args", "", "Space separated additional arguments of the scheduler started with --scheduler-binary")
 	flag.Parse()
 
-	var raHandlerFunc riskadvisorhandler.HTTPHandlerFunc
+	var adviseHandlerFunc, resilienceHandlerFunc riskadvisorhandler.HTTPHandlerFunc
 
-	ksf, err := kubeClient.New(*http.DefaultClient)
+	ksf, err := newStateFetcher(*snapshotFile, *kubeconfig, *context)
 	if err != nil {
-		errorMsg := "failed to communicate with cluster when building kubeClient"
+		errorMsg := "failed to create cluster state fetcher"
 		log.WithError(err).Error(errorMsg)
 
-		raHandlerFunc = riskadvisorhandler.ErrorResponseHandler(fmt.Errorf("%s (%s)", errorMsg, err))
+		adviseHandlerFunc = riskadvisorhandler.ErrorResponseHandler(fmt.Errorf("%s (%s)", errorMsg, err))
+		resilienceHandlerFunc = adviseHandlerFunc
 	} else {
-		raHandlerFunc = initializer.Initialize(*schedulerCommunicationPort, state.InitState, ksf)
+		adviseHandlerFunc, resilienceHandlerFunc = initializer.Initialize(*schedulerCommunicationPort, state.InitState, ksf, splitNamespaces(*namespaces), *longLived,
+			time.Duration(*simulationTimeout)*time.Second, strings.Split(*schedulers, ","))
 	}
 
-	raHandler := riskadvisorhandler.New(raHandlerFunc)
+	if *schedulerBinary != "" {
+		args := strings.Fields(*schedulerArgs)
+		if *schedulerPolicyFile != "" {
+			args = append(args, fmt.Sprintf("--policy-config-file=%s", *schedulerPolicyFile))
+		}
 
-	http.ListenAndServe(fmt.Sprintf(":%s", *raCommunicationPort), raHandler)
+		scheduler, err := localscheduler.Start(*schedulerBinary, *schedulerCommunicationPort, args)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to start scheduler")
+		}
+
+		// Do not leave the scheduler running when simulator is stopped
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-signals
+			scheduler.Process.Kill()
+			os.Exit(0)
+		}()
+	}
+
+	raHandler := riskadvisorhandler.New(adviseHandlerFunc, resilienceHandlerFunc)
+
+	http.ListenAndServe(net.JoinHostPort(*address, *raCommunicationPort), raHandler)
+}
+
+// Cluster state is read from the snapshot file if it is given, otherwise it is fetched from the cluster
+func newStateFetcher(snapshotFile, kubeconfig, context string) (kubeClient.ClusterStateFetcher, error) {
+	if snapshotFile != "" {
+		snapshot, err := kubeClient.ReadSnapshot(snapshotFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return kubeClient.NewSnapshotFetcher(snapshot), nil
+	}
+
+	ksf, err := kubeClient.New(*http.DefaultClient, kubeconfig, context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to communicate with cluster when building kubeClient: %s", err)
+	}
+
+	return ksf, nil
+}
+
+func splitNamespaces(namespaces string) []string {
+	if namespaces == "" {
+		return nil
+	}
+
+	return strings.Split(namespaces, ",")
 }
@@ -6,3 +6,6 @@ const SimulatorPort = "9998"
 const RiskAdvisorUserPort = "9997"
 const StartupTimeout = 145
 const RequestTimeout = 145
+const SimulationTimeout = 120
+const SimulatorNamespace = "default"
+const SimulatorPoolSize = 1
@@ -1,18 +1,38 @@
 package kubeClient
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	log "github.com/Sirupsen/logrus"
 	"k8s.io/client-go/1.5/kubernetes"
 	"k8s.io/client-go/1.5/pkg/api"
+	apierrors "k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
 	"k8s.io/client-go/1.5/pkg/api/v1"
-	"k8s.io/client-go/1.5/rest"
 	v1beta1 "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
 	"k8s.io/client-go/1.5/pkg/fields"
+	"k8s.io/client-go/1.5/rest"
+	"k8s.io/client-go/1.5/tools/clientcmd"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
 )
 
+// API versions of scheduling.k8s.io serving PriorityClasses, newest first
+var schedulingVersions = []string{"v1beta1", "v1alpha1"}
+
+// API versions of storage.k8s.io serving StorageClasses, newest first
+var storageVersions = []string{"v1", "v1beta1"}
+
+// API versions of apps serving StatefulSets, newest first
+var appsVersions = []string{"v1", "v1beta2", "v1beta1"}
+
+// API versions of policy serving PodDisruptionBudgets, newest first
+var policyVersions = []string{"v1", "v1beta1"}
+
 type ClusterCommunicator interface {
 	PodOperationHandler
 	ClusterStateFetcher
@@ -32,6 +52,14 @@ type ClusterStateFetcher interface {
 	GetReplicationControllers(namespace string) (*v1.ReplicationControllerList, error)
 	GetPods(namespace string, fieldSelector fields.Selector) (*v1.PodList, error)
 	GetNodes() (*v1.NodeList, error)
+	// Returns no classes if the cluster does not support pod priority or they must not be listed
+	GetPriorityClasses() ([]model.PriorityClass, error)
+	// Returns no classes if the cluster does not support storage classes or they must not be listed
+	GetStorageClasses() ([]model.StorageClass, error)
+	// Returns no objects if the cluster does not support StatefulSets
+	GetStatefulSets(namespace string) ([]model.OpaqueObject, error)
+	// Returns no objects if the cluster does not support PodDisruptionBudgets
+	GetPodDisruptionBudgets(namespace string) ([]model.OpaqueObject, error)
 }
 
 type kubernetesClient struct {
@@ -39,8 +67,10 @@ type kubernetesClient struct {
 	httpClient http.Client
 }
 
-func New(httpClient http.Client) (ClusterCommunicator, error) {
-	config, err := rest.InClusterConfig()
+// Uses the in-cluster configuration, unless kubeconfig file or context is given. Context is looked up in the
+// given kubeconfig file, or in files from the KUBECONFIG environment variable or ~/.kube/config, like in kubectl.
+func New(httpClient http.Client, kubeconfig, context string) (ClusterCommunicator, error) {
+	config, err := restConfig(kubeconfig, context)
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +86,18 @@ func New(httpClient http.Client) (ClusterCommunicator, error) {
 	}, nil
 }
 
+func restConfig(kubeconfig, context string) (*rest.Config, error) {
+	if kubeconfig == "" && context == "" {
+		return rest.InClusterConfig()
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
+
 func (kc *kubernetesClient) CreatePod(pod *v1.Pod, podName, namespace string, timeout int) (string, error) {
 	now := time.Now()
 	deadline := now.Add(time.Duration(timeout) * time.Second)
@@ -108,20 +150,42 @@ func (kc *kubernetesClient) DeletePod(namespace, podName string) error {
 	return kc.clientset.Core().Pods(namespace).Delete(podName, &api.DeleteOptions{})
 }
 
+// PVCs and PVs are fetched raw, so that their storage classes can be kept in annotations,
+// see model.DroppedVolumeSpecFields
 func (kc *kubernetesClient) GetPVCs(namespace string) (*v1.PersistentVolumeClaimList, error) {
-	return kc.clientset.Core().PersistentVolumeClaims(namespace).List(api.ListOptions{
-		ResourceVersion: "0",
-	})
+	listMeta, items, err := kc.listRaw(namespace, "persistentvolumeclaims", fields.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	pvcs := &v1.PersistentVolumeClaimList{ListMeta: listMeta, Items: make([]v1.PersistentVolumeClaim, len(items))}
+	for i, item := range items {
+		if err := decodeVolumeObject(item, &pvcs.Items[i], &pvcs.Items[i].ObjectMeta); err != nil {
+			return nil, fmt.Errorf("error unmarshalling persistent volume claim: %s", err)
+		}
+	}
+
+	return pvcs, nil
 }
 
 func (kc *kubernetesClient) GetPVs() (*v1.PersistentVolumeList, error) {
-	return kc.clientset.Core().PersistentVolumes().List(api.ListOptions{
-		ResourceVersion: "0",
-	})
+	listMeta, items, err := kc.listRaw(v1.NamespaceAll, "persistentvolumes", fields.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	pvs := &v1.PersistentVolumeList{ListMeta: listMeta, Items: make([]v1.PersistentVolume, len(items))}
+	for i, item := range items {
+		if err := decodeVolumeObject(item, &pvs.Items[i], &pvs.Items[i].ObjectMeta); err != nil {
+			return nil, fmt.Errorf("error unmarshalling persistent volume: %s", err)
+		}
+	}
+
+	return pvs, nil
 }
 
 func (kc *kubernetesClient) GetReplicaSets(namespace string) (*v1beta1.ReplicaSetList, error) {
-	return kc.clientset.ExtensionsClient.ReplicaSets("default").List(api.ListOptions{
+	return kc.clientset.ExtensionsClient.ReplicaSets(namespace).List(api.ListOptions{
 		ResourceVersion: "0",
 	})
 }
@@ -133,16 +197,27 @@ func (kc *kubernetesClient) GetServices(namespace string) (*v1.ServiceList, erro
 }
 
 func (kc *kubernetesClient) GetReplicationControllers(namespace string) (*v1.ReplicationControllerList, error) {
-	return kc.clientset.Core().ReplicationControllers("default").List(api.ListOptions{
+	return kc.clientset.Core().ReplicationControllers(namespace).List(api.ListOptions{
 		ResourceVersion: "0",
 	})
 }
 
+// Pods are fetched raw, so that priority fields of their specs can be kept in annotations,
+// see model.DroppedPodSpecFields
 func (kc *kubernetesClient) GetPods(namespace string, fieldSelector fields.Selector) (*v1.PodList, error) {
-	return kc.clientset.Core().Pods("default").List(api.ListOptions{
-		FieldSelector:   fieldSelector,
-		ResourceVersion: "0",
-	})
+	listMeta, items, err := kc.listRaw(namespace, "pods", fieldSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := &v1.PodList{ListMeta: listMeta, Items: make([]v1.Pod, len(items))}
+	for i, item := range items {
+		if err := decodePod(item, &pods.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return pods, nil
 }
 
 func (kc *kubernetesClient) GetNodes() (*v1.NodeList, error) {
@@ -150,3 +225,161 @@ func (kc *kubernetesClient) GetNodes() (*v1.NodeList, error) {
 		ResourceVersion: "0",
 	})
 }
+
+func (kc *kubernetesClient) GetPriorityClasses() ([]model.PriorityClass, error) {
+	for _, version := range schedulingVersions {
+		data, err := kc.clientset.Core().GetRESTClient().Get().
+			AbsPath("/apis/scheduling.k8s.io", version, "priorityclasses").
+			DoRaw()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if apierrors.IsForbidden(err) {
+			log.WithError(err).Warn("Not allowed to list PriorityClasses, pods are simulated without priorities")
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var list struct {
+			Items []model.PriorityClass `json:"items"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("error unmarshalling priority classes: %s", err)
+		}
+
+		return list.Items, nil
+	}
+
+	return nil, nil
+}
+
+func (kc *kubernetesClient) GetStorageClasses() ([]model.StorageClass, error) {
+	for _, version := range storageVersions {
+		data, err := kc.clientset.Core().GetRESTClient().Get().
+			AbsPath("/apis/storage.k8s.io", version, "storageclasses").
+			DoRaw()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if apierrors.IsForbidden(err) {
+			log.WithError(err).Warn("Not allowed to list StorageClasses, claims are not provisioned dynamically")
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var list struct {
+			Items []model.StorageClass `json:"items"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("error unmarshalling storage classes: %s", err)
+		}
+
+		return list.Items, nil
+	}
+
+	return nil, nil
+}
+
+func (kc *kubernetesClient) GetStatefulSets(namespace string) ([]model.OpaqueObject, error) {
+	return kc.listOpaque("/apis/apps", appsVersions, namespace, "statefulsets")
+}
+
+func (kc *kubernetesClient) GetPodDisruptionBudgets(namespace string) ([]model.OpaqueObject, error) {
+	return kc.listOpaque("/apis/policy", policyVersions, namespace, "poddisruptionbudgets")
+}
+
+// Lists objects of the newest version of the API group served by the cluster
+func (kc *kubernetesClient) listOpaque(groupPath string, versions []string, namespace,
+	resource string) ([]model.OpaqueObject, error) {
+	for _, version := range versions {
+		path := []string{groupPath, version, resource}
+		if namespace != v1.NamespaceAll {
+			path = []string{groupPath, version, "namespaces", namespace, resource}
+		}
+
+		data, err := kc.clientset.Core().GetRESTClient().Get().
+			AbsPath(path...).
+			DoRaw()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if apierrors.IsForbidden(err) {
+			log.WithError(err).Warnf("Not allowed to list %s, they are not served to the scheduler", resource)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var list struct {
+			Items []model.OpaqueObject `json:"items"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("error unmarshalling %s: %s", resource, err)
+		}
+
+		return list.Items, nil
+	}
+
+	return nil, nil
+}
+
+// Lists objects of the core API group without decoding them
+func (kc *kubernetesClient) listRaw(namespace, resource string, fieldSelector fields.Selector) (unversioned.ListMeta,
+	[]json.RawMessage, error) {
+	data, err := kc.clientset.Core().GetRESTClient().Get().
+		Namespace(namespace).
+		Resource(resource).
+		FieldsSelectorParam(fieldSelector).
+		Param("resourceVersion", "0").
+		DoRaw()
+	if err != nil {
+		return unversioned.ListMeta{}, nil, err
+	}
+
+	var list struct {
+		unversioned.ListMeta `json:"metadata,omitempty"`
+		Items                []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return unversioned.ListMeta{}, nil, fmt.Errorf("error unmarshalling %s: %s", resource, err)
+	}
+
+	return list.ListMeta, list.Items, nil
+}
+
+// Decodes the PV or PVC into the object with the given metadata, keeping its storage class in the annotation
+func decodeVolumeObject(data []byte, object interface{}, meta *v1.ObjectMeta) error {
+	var spec struct {
+		Spec model.DroppedVolumeSpecFields `json:"spec"`
+	}
+	if err := json.Unmarshal(data, object); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	spec.Spec.Apply(meta)
+
+	return nil
+}
+
+// Decodes the pod keeping fields dropped by v1.Pod in annotations
+func decodePod(data []byte, pod *v1.Pod) error {
+	var spec struct {
+		Spec model.DroppedPodSpecFields `json:"spec"`
+	}
+	if err := json.Unmarshal(data, pod); err != nil {
+		return fmt.Errorf("error unmarshalling pod: %s", err)
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("error unmarshalling pod: %s", err)
+	}
+	spec.Spec.Apply(pod)
+
+	return nil
+}
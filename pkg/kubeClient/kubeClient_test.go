@@ -0,0 +1,99 @@
+package kubeClient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/rest"
+
+	"github.com/Prytu/risk-advisor/pkg/testutil"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: production
+  cluster:
+    server: https://production.example.com
+- name: staging
+  cluster:
+    server: https://staging.example.com
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: production
+  context:
+    cluster: production
+    user: admin
+- name: staging
+  context:
+    cluster: staging
+    user: admin
+current-context: production
+`
+
+func TestRestConfigUsesCurrentContextOfKubeconfig(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", testKubeconfig)
+	defer os.Remove(filename)
+
+	config, err := restConfig(filename, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://production.example.com", config.Host)
+	assert.Equal(t, "secret", config.BearerToken)
+}
+
+func TestRestConfigUsesGivenContext(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", testKubeconfig)
+	defer os.Remove(filename)
+
+	config, err := restConfig(filename, "staging")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://staging.example.com", config.Host)
+}
+
+func TestRestConfigFailsForUnknownContext(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", testKubeconfig)
+	defer os.Remove(filename)
+
+	_, err := restConfig(filename, "development")
+
+	assert.Error(t, err)
+}
+
+func TestForbiddenObjectsAreNotListed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","code":403}`))
+	}))
+	defer server.Close()
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	kc := &kubernetesClient{clientset: clientset}
+
+	priorityClasses, err := kc.GetPriorityClasses()
+	assert.NoError(t, err)
+	assert.Empty(t, priorityClasses)
+
+	storageClasses, err := kc.GetStorageClasses()
+	assert.NoError(t, err)
+	assert.Empty(t, storageClasses)
+
+	statefulSets, err := kc.GetStatefulSets(v1.NamespaceAll)
+	assert.NoError(t, err)
+	assert.Empty(t, statefulSets)
+
+	podDisruptionBudgets, err := kc.GetPodDisruptionBudgets("default")
+	assert.NoError(t, err)
+	assert.Empty(t, podDisruptionBudgets)
+}
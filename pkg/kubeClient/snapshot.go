@@ -0,0 +1,316 @@
+package kubeClient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/fields"
+	"k8s.io/client-go/1.5/pkg/util/yaml"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Resource version of snapshots that do not set one
+const defaultSnapshotResourceVersion = "1"
+
+// ClusterStateFetcher serving objects from a snapshot, every call returns new lists
+type snapshotFetcher struct {
+	snapshot *model.Snapshot
+}
+
+// Reads a snapshot from a JSON or YAML file. Besides Snapshot, the file can contain a List of objects,
+// e.g. the output of `kubectl get nodes,pods,pv,pvc,rs,svc,rc,storageclasses,sts,pdb --all-namespaces -o yaml`,
+// or a SimulationBundle stored by risk-advisor.
+func ReadSnapshot(filename string) (*model.Snapshot, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot file: %s", err)
+	}
+
+	data, err = yaml.ToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error converting snapshot to JSON: %s", err)
+	}
+
+	var bundle struct {
+		Version  string          `json:"version"`
+		Snapshot json.RawMessage `json:"snapshot"`
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("error unmarshalling snapshot: %s", err)
+	}
+	if bundle.Version != "" && bundle.Snapshot != nil {
+		if bundle.Version != model.SimulationBundleVersion {
+			return nil, fmt.Errorf("unsupported simulation bundle version %s", bundle.Version)
+		}
+		data = bundle.Snapshot
+	}
+
+	var list struct {
+		unversioned.TypeMeta `json:",inline"`
+		unversioned.ListMeta `json:"metadata,omitempty"`
+		Items                []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("error unmarshalling snapshot: %s", err)
+	}
+
+	snapshot := &model.Snapshot{}
+	if list.Kind == "List" {
+		snapshot, err = snapshotFromList(list.ResourceVersion, list.Items)
+	} else {
+		err = snapshotFromObject(data, snapshot)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling snapshot: %s", err)
+	}
+
+	setDefaultNamespaces(snapshot)
+
+	return snapshot, nil
+}
+
+func NewSnapshotFetcher(snapshot *model.Snapshot) ClusterStateFetcher {
+	return &snapshotFetcher{snapshot: snapshot}
+}
+
+func snapshotFromObject(data []byte, snapshot *model.Snapshot) error {
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return err
+	}
+
+	var raw struct {
+		Pods                   []json.RawMessage `json:"pods"`
+		PersistentVolumes      []json.RawMessage `json:"persistentVolumes"`
+		PersistentVolumeClaims []json.RawMessage `json:"persistentVolumeClaims"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for i, pod := range raw.Pods {
+		if err := decodePod(pod, &snapshot.Pods[i]); err != nil {
+			return fmt.Errorf("pod %d: %s", i, err)
+		}
+	}
+	pvs, pvcs := snapshot.PersistentVolumes, snapshot.PersistentVolumeClaims
+	for i, pv := range raw.PersistentVolumes {
+		if err := decodeVolumeObject(pv, &pvs[i], &pvs[i].ObjectMeta); err != nil {
+			return fmt.Errorf("persistent volume %d: %s", i, err)
+		}
+	}
+	for i, pvc := range raw.PersistentVolumeClaims {
+		if err := decodeVolumeObject(pvc, &pvcs[i], &pvcs[i].ObjectMeta); err != nil {
+			return fmt.Errorf("persistent volume claim %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+func snapshotFromList(resourceVersion string, items []json.RawMessage) (*model.Snapshot, error) {
+	snapshot := &model.Snapshot{ResourceVersion: resourceVersion}
+
+	for i, item := range items {
+		var typeMeta unversioned.TypeMeta
+		if err := json.Unmarshal(item, &typeMeta); err != nil {
+			return nil, fmt.Errorf("item %d: %s", i, err)
+		}
+
+		var err error
+		switch typeMeta.Kind {
+		case "Node":
+			var node v1.Node
+			err = json.Unmarshal(item, &node)
+			snapshot.Nodes = append(snapshot.Nodes, node)
+		case "Pod":
+			var pod v1.Pod
+			err = decodePod(item, &pod)
+			snapshot.Pods = append(snapshot.Pods, pod)
+		case "PersistentVolume":
+			var pv v1.PersistentVolume
+			err = decodeVolumeObject(item, &pv, &pv.ObjectMeta)
+			snapshot.PersistentVolumes = append(snapshot.PersistentVolumes, pv)
+		case "PersistentVolumeClaim":
+			var pvc v1.PersistentVolumeClaim
+			err = decodeVolumeObject(item, &pvc, &pvc.ObjectMeta)
+			snapshot.PersistentVolumeClaims = append(snapshot.PersistentVolumeClaims, pvc)
+		case "ReplicaSet":
+			var replicaSet v1beta1.ReplicaSet
+			err = json.Unmarshal(item, &replicaSet)
+			snapshot.ReplicaSets = append(snapshot.ReplicaSets, replicaSet)
+		case "Service":
+			var service v1.Service
+			err = json.Unmarshal(item, &service)
+			snapshot.Services = append(snapshot.Services, service)
+		case "ReplicationController":
+			var replicationController v1.ReplicationController
+			err = json.Unmarshal(item, &replicationController)
+			snapshot.ReplicationControllers = append(snapshot.ReplicationControllers, replicationController)
+		case "PriorityClass":
+			var priorityClass model.PriorityClass
+			err = json.Unmarshal(item, &priorityClass)
+			snapshot.PriorityClasses = append(snapshot.PriorityClasses, priorityClass)
+		case "StorageClass":
+			var storageClass model.StorageClass
+			err = json.Unmarshal(item, &storageClass)
+			snapshot.StorageClasses = append(snapshot.StorageClasses, storageClass)
+		case "StatefulSet":
+			var statefulSet model.OpaqueObject
+			err = json.Unmarshal(item, &statefulSet)
+			snapshot.StatefulSets = append(snapshot.StatefulSets, statefulSet)
+		case "PodDisruptionBudget":
+			var budget model.OpaqueObject
+			err = json.Unmarshal(item, &budget)
+			snapshot.PodDisruptionBudgets = append(snapshot.PodDisruptionBudgets, budget)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s (item %d): %s", typeMeta.Kind, i, err)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Namespaced objects without namespace are in the default namespace, like in kubectl
+func setDefaultNamespaces(snapshot *model.Snapshot) {
+	for i := range snapshot.Pods {
+		defaultNamespace(&snapshot.Pods[i].ObjectMeta)
+	}
+	for i := range snapshot.PersistentVolumeClaims {
+		defaultNamespace(&snapshot.PersistentVolumeClaims[i].ObjectMeta)
+	}
+	for i := range snapshot.ReplicaSets {
+		defaultNamespace(&snapshot.ReplicaSets[i].ObjectMeta)
+	}
+	for i := range snapshot.Services {
+		defaultNamespace(&snapshot.Services[i].ObjectMeta)
+	}
+	for i := range snapshot.ReplicationControllers {
+		defaultNamespace(&snapshot.ReplicationControllers[i].ObjectMeta)
+	}
+	for i := range snapshot.StatefulSets {
+		defaultNamespace(&snapshot.StatefulSets[i].ObjectMeta)
+	}
+	for i := range snapshot.PodDisruptionBudgets {
+		defaultNamespace(&snapshot.PodDisruptionBudgets[i].ObjectMeta)
+	}
+}
+
+func defaultNamespace(meta *v1.ObjectMeta) {
+	if meta.Namespace == "" {
+		meta.Namespace = v1.NamespaceDefault
+	}
+}
+
+func (sf *snapshotFetcher) GetPVCs(namespace string) (*v1.PersistentVolumeClaimList, error) {
+	pvcs := &v1.PersistentVolumeClaimList{ListMeta: sf.listMeta()}
+	for _, pvc := range sf.snapshot.PersistentVolumeClaims {
+		if inNamespace(pvc.Namespace, namespace) {
+			pvcs.Items = append(pvcs.Items, pvc)
+		}
+	}
+
+	return pvcs, nil
+}
+
+func (sf *snapshotFetcher) GetPVs() (*v1.PersistentVolumeList, error) {
+	pvs := append([]v1.PersistentVolume(nil), sf.snapshot.PersistentVolumes...)
+	return &v1.PersistentVolumeList{ListMeta: sf.listMeta(), Items: pvs}, nil
+}
+
+func (sf *snapshotFetcher) GetReplicaSets(namespace string) (*v1beta1.ReplicaSetList, error) {
+	replicaSets := &v1beta1.ReplicaSetList{ListMeta: sf.listMeta()}
+	for _, replicaSet := range sf.snapshot.ReplicaSets {
+		if inNamespace(replicaSet.Namespace, namespace) {
+			replicaSets.Items = append(replicaSets.Items, replicaSet)
+		}
+	}
+
+	return replicaSets, nil
+}
+
+func (sf *snapshotFetcher) GetServices(namespace string) (*v1.ServiceList, error) {
+	services := &v1.ServiceList{ListMeta: sf.listMeta()}
+	for _, service := range sf.snapshot.Services {
+		if inNamespace(service.Namespace, namespace) {
+			services.Items = append(services.Items, service)
+		}
+	}
+
+	return services, nil
+}
+
+func (sf *snapshotFetcher) GetReplicationControllers(namespace string) (*v1.ReplicationControllerList, error) {
+	replicationControllers := &v1.ReplicationControllerList{ListMeta: sf.listMeta()}
+	for _, replicationController := range sf.snapshot.ReplicationControllers {
+		if inNamespace(replicationController.Namespace, namespace) {
+			replicationControllers.Items = append(replicationControllers.Items, replicationController)
+		}
+	}
+
+	return replicationControllers, nil
+}
+
+func (sf *snapshotFetcher) GetPods(namespace string, fieldSelector fields.Selector) (*v1.PodList, error) {
+	pods := &v1.PodList{ListMeta: sf.listMeta()}
+	for _, pod := range sf.snapshot.Pods {
+		podFields := fields.Set{
+			"spec.nodeName": pod.Spec.NodeName,
+			"status.phase":  string(pod.Status.Phase),
+		}
+		if inNamespace(pod.Namespace, namespace) && fieldSelector.Matches(podFields) {
+			pods.Items = append(pods.Items, pod)
+		}
+	}
+
+	return pods, nil
+}
+
+func (sf *snapshotFetcher) GetNodes() (*v1.NodeList, error) {
+	nodes := append([]v1.Node(nil), sf.snapshot.Nodes...)
+	return &v1.NodeList{ListMeta: sf.listMeta(), Items: nodes}, nil
+}
+
+func (sf *snapshotFetcher) GetPriorityClasses() ([]model.PriorityClass, error) {
+	return append([]model.PriorityClass(nil), sf.snapshot.PriorityClasses...), nil
+}
+
+func (sf *snapshotFetcher) GetStorageClasses() ([]model.StorageClass, error) {
+	return append([]model.StorageClass(nil), sf.snapshot.StorageClasses...), nil
+}
+
+func (sf *snapshotFetcher) GetStatefulSets(namespace string) ([]model.OpaqueObject, error) {
+	return opaqueInNamespace(sf.snapshot.StatefulSets, namespace), nil
+}
+
+func (sf *snapshotFetcher) GetPodDisruptionBudgets(namespace string) ([]model.OpaqueObject, error) {
+	return opaqueInNamespace(sf.snapshot.PodDisruptionBudgets, namespace), nil
+}
+
+func opaqueInNamespace(objects []model.OpaqueObject, namespace string) []model.OpaqueObject {
+	var result []model.OpaqueObject
+	for _, object := range objects {
+		if inNamespace(object.Namespace, namespace) {
+			result = append(result, object)
+		}
+	}
+
+	return result
+}
+
+func (sf *snapshotFetcher) listMeta() unversioned.ListMeta {
+	resourceVersion := sf.snapshot.ResourceVersion
+	if resourceVersion == "" {
+		resourceVersion = defaultSnapshotResourceVersion
+	}
+
+	return unversioned.ListMeta{ResourceVersion: resourceVersion}
+}
+
+func inNamespace(objectNamespace, namespace string) bool {
+	return namespace == v1.NamespaceAll || objectNamespace == namespace
+}
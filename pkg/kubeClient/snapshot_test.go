@@ -0,0 +1,195 @@
+package kubeClient
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/fields"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+	"github.com/Prytu/risk-advisor/pkg/testutil"
+)
+
+func TestReadSnapshotFromKubectlList(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: node
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: running
+  spec:
+    nodeName: node
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pending
+    namespace: team
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: ignored
+`)
+	defer os.Remove(filename)
+
+	snapshot, err := ReadSnapshot(filename)
+	assert.NoError(t, err)
+	fetcher := NewSnapshotFetcher(snapshot)
+
+	nodes, err := fetcher.GetNodes()
+	assert.NoError(t, err)
+	assert.Len(t, nodes.Items, 1)
+	assert.Equal(t, defaultSnapshotResourceVersion, nodes.ResourceVersion)
+
+	assignedPods, err := fetcher.GetPods(v1.NamespaceAll, fields.ParseSelectorOrDie("spec.nodeName!="))
+	assert.NoError(t, err)
+	if assert.Len(t, assignedPods.Items, 1) {
+		assert.Equal(t, "running", assignedPods.Items[0].Name)
+		assert.Equal(t, v1.NamespaceDefault, assignedPods.Items[0].Namespace)
+	}
+
+	teamPods, err := fetcher.GetPods("team", fields.Everything())
+	assert.NoError(t, err)
+	if assert.Len(t, teamPods.Items, 1) {
+		assert.Equal(t, "pending", teamPods.Items[0].Name)
+	}
+}
+
+func TestReadSnapshotKeepsStatefulSetsAndPodDisruptionBudgets(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: StatefulSet
+  metadata:
+    name: db
+  spec:
+    serviceName: db
+- apiVersion: policy/v1beta1
+  kind: PodDisruptionBudget
+  metadata:
+    name: db
+    namespace: team
+  spec:
+    minAvailable: 1
+`)
+	defer os.Remove(filename)
+
+	snapshot, err := ReadSnapshot(filename)
+	assert.NoError(t, err)
+	fetcher := NewSnapshotFetcher(snapshot)
+
+	statefulSets, err := fetcher.GetStatefulSets(v1.NamespaceDefault)
+	assert.NoError(t, err)
+	if assert.Len(t, statefulSets, 1) {
+		assert.Equal(t, "db", statefulSets[0].Name)
+		assert.JSONEq(t, `{"serviceName":"db"}`, string(statefulSets[0].Spec))
+	}
+
+	budgets, err := fetcher.GetPodDisruptionBudgets(v1.NamespaceDefault)
+	assert.NoError(t, err)
+	assert.Empty(t, budgets)
+	budgets, err = fetcher.GetPodDisruptionBudgets("team")
+	assert.NoError(t, err)
+	assert.Len(t, budgets, 1)
+}
+
+func TestReadSnapshotKeepsPriorities(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: scheduling.k8s.io/v1beta1
+  kind: PriorityClass
+  metadata:
+    name: high
+  value: 1000
+  globalDefault: true
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pod
+  spec:
+    priorityClassName: high
+    priority: 1000
+`)
+	defer os.Remove(filename)
+
+	snapshot, err := ReadSnapshot(filename)
+	assert.NoError(t, err)
+	fetcher := NewSnapshotFetcher(snapshot)
+
+	priorityClasses, err := fetcher.GetPriorityClasses()
+	assert.NoError(t, err)
+	if assert.Len(t, priorityClasses, 1) {
+		assert.Equal(t, "high", priorityClasses[0].Name)
+		assert.Equal(t, int32(1000), priorityClasses[0].Value)
+		assert.True(t, priorityClasses[0].GlobalDefault)
+	}
+
+	pods, err := fetcher.GetPods(v1.NamespaceAll, fields.Everything())
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		assert.Equal(t, "high", pods.Items[0].Annotations[model.PriorityClassNameAnnotationKey])
+		assert.Equal(t, "1000", pods.Items[0].Annotations[model.PriorityAnnotationKey])
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", `{
+		"resourceVersion": "42",
+		"nodes": [{"metadata": {"name": "node"}}],
+		"services": [{"metadata": {"name": "web", "namespace": "team"}}]
+	}`)
+	defer os.Remove(filename)
+
+	snapshot, err := ReadSnapshot(filename)
+	assert.NoError(t, err)
+	fetcher := NewSnapshotFetcher(snapshot)
+
+	nodes, err := fetcher.GetNodes()
+	assert.NoError(t, err)
+	assert.Equal(t, "42", nodes.ResourceVersion)
+
+	services, err := fetcher.GetServices("default")
+	assert.NoError(t, err)
+	assert.Empty(t, services.Items)
+	services, err = fetcher.GetServices("team")
+	assert.NoError(t, err)
+	assert.Len(t, services.Items, 1)
+}
+
+func TestReadSnapshotFromSimulationBundle(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", `{
+		"version": "v1",
+		"snapshot": {"nodes": [{"metadata": {"name": "node"}}], "pods": []},
+		"request": {"toCreate": []},
+		"response": {"results": []}
+	}`)
+	defer os.Remove(filename)
+
+	snapshot, err := ReadSnapshot(filename)
+
+	assert.NoError(t, err)
+	if assert.Len(t, snapshot.Nodes, 1) {
+		assert.Equal(t, "node", snapshot.Nodes[0].Name)
+	}
+}
+
+func TestReadSnapshotFromUnsupportedBundleVersion(t *testing.T) {
+	filename := testutil.WriteTempFile(t, "kubeClient", `{"version": "v0", "snapshot": {"nodes": []}}`)
+	defer os.Remove(filename)
+
+	_, err := ReadSnapshot(filename)
+
+	assert.Error(t, err)
+}
@@ -1,17 +1,145 @@
+// Package model contains types exchanged between risk-advisor, simulators and schedulers.
+//
+// The project is built with client-go 1.5, which lacks types and fields of newer API versions, like PriorityClasses,
+// StorageClasses, StatefulSets, pod priority, spec.schedulerName or spec.storageClassName. Missing types are defined
+// here with only the fields needed by the simulation, missing fields are kept in annotations of the objects.
 package model
 
-import "k8s.io/client-go/1.5/pkg/api/v1"
+import (
+	"fmt"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
 
 const MaxNameLength = 58
 
+// Result of pods that did not get a scheduling decision before the simulation timed out
+const TimedOutResult = "TimedOut"
+
+// Result of pods handled by a scheduler that does not run in the simulator
+const UnsupportedSchedulerResult = "UnsupportedScheduler"
+
+// Pods choose their scheduler with this annotation, see DroppedPodSpecFields
+const SchedulerNameAnnotationKey = "scheduler.alpha.kubernetes.io/name"
+
 type SimulatorRequest struct {
 	ToCreate []*v1.Pod `json:"toCreate" binding:"required"`
 	ToDelete []*v1.Pod `json:"toDelete"`
+	// PVCs created before pods are scheduled, they are bound to available PVs or provisioned by their storage class
+	PersistentVolumeClaims []*v1.PersistentVolumeClaim `json:"persistentVolumeClaims,omitempty"`
+	// Changes of nodes applied before pods are scheduled
+	Nodes *NodeMutations `json:"nodes,omitempty"`
+	// Templates of nodes to estimate how many of them are needed by pods that failed scheduling.
+	// Count of a template is the maximum number of its nodes, DefaultMaxEstimatedNodes if not set
+	// and at most MaxTemplateNodes.
+	Autoscale []NodeTemplate `json:"autoscale,omitempty"`
+	// Maximum duration of the simulation, simulator's default is used if not set
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+	// Respond with SimulatorResponse containing the capacity projection instead of the table of results
+	Capacity bool `json:"capacity,omitempty"`
+	// Respond with SimulatorResponse containing the snapshot of the state the simulation started from
+	Snapshot bool `json:"snapshot,omitempty"`
+}
+
+type SimulatorResponse struct {
+	Results  []*SchedulingResult `json:"results"`
+	Capacity []NodeCapacity      `json:"capacity,omitempty"`
+	// Results of binding PVCs of the request
+	PersistentVolumeClaims []ClaimResult `json:"persistentVolumeClaims,omitempty"`
+	// Results of pods of removed and drained nodes, which had to be scheduled again
+	Evicted []*SchedulingResult `json:"evicted,omitempty"`
+	// Estimated number of nodes needed by pods that failed scheduling, per requested template
+	Autoscaling []NodeEstimate `json:"autoscaling,omitempty"`
+	// Results grouped per workload, filled by risk-advisor for requests containing workloads
+	Workloads []WorkloadResult `json:"workloads,omitempty"`
+	// Objects of the request that were not simulated, in "Kind namespace/name" format
+	Ignored []string `json:"ignored,omitempty"`
+	// State of the cluster the simulation started from, set only if requested
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+}
+
+const SimulationBundleVersion = "v1"
+
+// Everything needed to replay a simulation offline: the state of the cluster the simulation started from,
+// the simulator request and its results
+type SimulationBundle struct {
+	Version  string             `json:"version"`
+	Snapshot *Snapshot          `json:"snapshot"`
+	Request  *SimulatorRequest  `json:"request"`
+	Response *SimulatorResponse `json:"response"`
+}
+
+// Summary of scheduling results of pods of a workload, e.g. a Deployment
+type WorkloadResult struct {
+	Kind string `json:"kind"`
+	// Workload identity in namespace/name format
+	Name      string `json:"name"`
+	Replicas  int    `json:"replicas"`
+	Scheduled int    `json:"scheduled"`
+	Message   string `json:"message"`
+}
+
+// Resources of a node and resources requested by pods on that node before and after the simulation
+type NodeCapacity struct {
+	NodeName        string    `json:"nodeName"`
+	Allocatable     Resources `json:"allocatable"`
+	RequestedBefore Resources `json:"requestedBefore"`
+	RequestedAfter  Resources `json:"requestedAfter"`
+}
+
+type Resources struct {
+	MilliCPU int64 `json:"milliCpu"`
+	// Memory in bytes
+	Memory int64 `json:"memory"`
+	Pods   int64 `json:"pods"`
 }
 
 type SchedulingResult struct {
-	PodName      string `json:"podName,omitempty"`
-	Result       string `json:"result,omitempty"`
-	Message      string `json:"message,omitempty"`
-	ErrorMessage string `json:"errorMessage,omitempty"`
+	// Pod identity in namespace/name format, see PodKey
+	PodName string `json:"podName,omitempty"`
+	Result  string `json:"result,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Node the pod was bound to, set for scheduled pods
+	NodeName string `json:"nodeName,omitempty"`
+	// Map node name to the reasons why the pod does not fit on it, set for pods that failed scheduling
+	FailedPredicates map[string][]string `json:"failedPredicates,omitempty"`
+	// Map reasons to the number of nodes the pod does not fit on because of them, set instead of FailedPredicates
+	// when the scheduler (1.6 and later) does not report reasons per node
+	FailedPredicateCounts map[string]int `json:"failedPredicateCounts,omitempty"`
+	// Node the scheduler chose for the pod by preempting other pods
+	NominatedNodeName string `json:"nominatedNodeName,omitempty"`
+	// Existing pods deleted by the scheduler to make room for the pod, in namespace/name format
+	PreemptedPods []string `json:"preemptedPods,omitempty"`
+	ErrorMessage  string   `json:"errorMessage,omitempty"`
+}
+
+// Identifies a pod in the cluster, pod names are unique only within a namespace.
+func PodKey(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+// Returns the name of the scheduler responsible for the pod
+func PodSchedulerName(pod *v1.Pod) string {
+	if name := pod.Annotations[SchedulerNameAnnotationKey]; name != "" {
+		return name
+	}
+
+	return v1.DefaultSchedulerName
+}
+
+// State of the cluster saved to a file, used to simulate scheduling without access to the cluster
+type Snapshot struct {
+	ResourceVersion        string                     `json:"resourceVersion,omitempty"`
+	Nodes                  []v1.Node                  `json:"nodes"`
+	Pods                   []v1.Pod                   `json:"pods"`
+	PersistentVolumes      []v1.PersistentVolume      `json:"persistentVolumes,omitempty"`
+	PersistentVolumeClaims []v1.PersistentVolumeClaim `json:"persistentVolumeClaims,omitempty"`
+	ReplicaSets            []v1beta1.ReplicaSet       `json:"replicaSets,omitempty"`
+	Services               []v1.Service               `json:"services,omitempty"`
+	ReplicationControllers []v1.ReplicationController `json:"replicationControllers,omitempty"`
+	PriorityClasses        []PriorityClass            `json:"priorityClasses,omitempty"`
+	StorageClasses         []StorageClass             `json:"storageClasses,omitempty"`
+	StatefulSets           []OpaqueObject             `json:"statefulSets,omitempty"`
+	PodDisruptionBudgets   []OpaqueObject             `json:"podDisruptionBudgets,omitempty"`
 }
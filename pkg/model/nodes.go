@@ -0,0 +1,67 @@
+package model
+
+import (
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+// Changes of nodes of the cluster applied before pods are scheduled, e.g. to check whether a node can be drained
+// for maintenance or whether new nodes would fit a release. Nodes are identified by name.
+type NodeMutations struct {
+	// Synthetic nodes to add to the cluster
+	Add []NodeTemplate `json:"add,omitempty"`
+	// Nodes to remove from the cluster, their pods are scheduled again on other nodes
+	Remove []string `json:"remove,omitempty"`
+	// Nodes to mark unschedulable, their pods keep running
+	Cordon []string `json:"cordon,omitempty"`
+	// Nodes to mark unschedulable and evict pods from, like `kubectl drain` does. Evicted pods are scheduled again.
+	Drain []string `json:"drain,omitempty"`
+}
+
+// Name of nodes added from templates without name
+const DefaultNodeTemplateName = "simulated-node"
+
+// Template of nodes added to the cluster, either a full node or a copy of an existing node
+type NodeTemplate struct {
+	Node *v1.Node `json:"node,omitempty"`
+	// Name of an existing node to copy, used if Node is not given
+	CopyOf string `json:"copyOf,omitempty"`
+	// Number of nodes to add, 1 if not set and at most MaxTemplateNodes. Nodes are named <name>-<n> unless
+	// only one named node is added, names of existing nodes are skipped.
+	Count int `json:"count,omitempty"`
+}
+
+// Name of the template node, or of the node it copies
+func (t *NodeTemplate) Name() string {
+	switch {
+	case t.Node != nil && t.Node.Name != "":
+		return t.Node.Name
+	case t.Node == nil && t.CopyOf != "":
+		return t.CopyOf
+	default:
+		return DefaultNodeTemplateName
+	}
+}
+
+// Maximum count of a node template, so that a single request cannot add an unbounded number of nodes to the state
+const MaxTemplateNodes = 1000
+
+// Maximum number of nodes added per template when estimating nodes needed by pods that failed scheduling,
+// used if the template does not set its count
+const DefaultMaxEstimatedNodes = 10
+
+// Number of nodes of a template that have to be added to the cluster for pods that failed scheduling to fit,
+// estimated like the cluster autoscaler does
+type NodeEstimate struct {
+	// Name of the template node, or of the node it copies
+	Template string `json:"template"`
+	// Minimal number of nodes that fit as many pods as the maximum number of nodes does
+	Nodes    int `json:"nodes"`
+	MaxNodes int `json:"maxNodes"`
+	// Pods that do not fit even with the maximum number of nodes, in namespace/name format
+	Unschedulable []string `json:"unschedulable,omitempty"`
+	Message       string   `json:"message,omitempty"`
+}
+
+func (m *NodeMutations) IsEmpty() bool {
+	return m == nil || len(m.Add) == 0 && len(m.Remove) == 0 && len(m.Cordon) == 0 && len(m.Drain) == 0
+}
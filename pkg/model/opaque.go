@@ -0,0 +1,15 @@
+package model
+
+import (
+	"encoding/json"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+// Object the simulator does not interpret, it only serves it to the scheduler, e.g. a StatefulSet
+// or a PodDisruptionBudget. Spec and status are kept as they were fetched.
+type OpaqueObject struct {
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Spec          json.RawMessage `json:"spec,omitempty"`
+	Status        json.RawMessage `json:"status,omitempty"`
+}
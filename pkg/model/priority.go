@@ -0,0 +1,76 @@
+package model
+
+import (
+	"strconv"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+// Annotations keeping the priority fields of pods, see DroppedPodSpecFields
+const (
+	PriorityClassNameAnnotationKey = "risk-advisor/priority-class-name"
+	PriorityAnnotationKey          = "risk-advisor/priority"
+)
+
+// PriorityClass with only the fields needed to resolve priorities of pods
+type PriorityClass struct {
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Value         int32  `json:"value"`
+	GlobalDefault bool   `json:"globalDefault,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+// Fields of the pod spec of newer API versions, which are dropped when the pod is decoded into v1.Pod
+type DroppedPodSpecFields struct {
+	SchedulerName     string `json:"schedulerName"`
+	PriorityClassName string `json:"priorityClassName"`
+	Priority          *int32 `json:"priority"`
+}
+
+// Keeps the fields in annotations of the pod, annotations that are already set are not overwritten.
+// Annotations map is copied, because pods created from the same template share it.
+func (fields *DroppedPodSpecFields) Apply(pod *v1.Pod) {
+	annotations := make(map[string]string)
+	if fields.SchedulerName != "" {
+		annotations[SchedulerNameAnnotationKey] = fields.SchedulerName
+	}
+	if fields.PriorityClassName != "" {
+		annotations[PriorityClassNameAnnotationKey] = fields.PriorityClassName
+	}
+	if fields.Priority != nil {
+		annotations[PriorityAnnotationKey] = strconv.FormatInt(int64(*fields.Priority), 10)
+	}
+	if len(annotations) == 0 {
+		return
+	}
+
+	for key, value := range pod.Annotations {
+		annotations[key] = value
+	}
+	pod.Annotations = annotations
+}
+
+// Returns the priority of the pod, nil if it has not been resolved
+func PodPriority(pod *v1.Pod) *int32 {
+	value, ok := pod.Annotations[PriorityAnnotationKey]
+	if !ok {
+		return nil
+	}
+
+	priority, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return nil
+	}
+
+	result := int32(priority)
+	return &result
+}
+
+// Returns the fields kept in annotations of the pod, see DroppedPodSpecFields.Apply
+func PodDroppedSpecFields(pod *v1.Pod) DroppedPodSpecFields {
+	return DroppedPodSpecFields{
+		SchedulerName:     pod.Annotations[SchedulerNameAnnotationKey],
+		PriorityClassName: pod.Annotations[PriorityClassNameAnnotationKey],
+		Priority:          PodPriority(pod),
+	}
+}
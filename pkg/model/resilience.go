@@ -0,0 +1,34 @@
+package model
+
+type ResilienceRequest struct {
+	// Label grouping nodes into failure domains, e.g. failure-domain.beta.kubernetes.io/zone.
+	// Every node is a failure domain of its own if not set, so is every node without the label.
+	DomainLabel string `json:"domainLabel,omitempty"`
+	// Maximum duration of the whole analysis, simulator's default is used if not set
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+}
+
+type ResilienceResponse struct {
+	DomainLabel string `json:"domainLabel,omitempty"`
+	// Failures of every domain, sorted by domain
+	Domains []DomainFailure `json:"domains"`
+	// Domains whose failure leaves some workloads with fewer pods than before
+	Unrecoverable []string `json:"unrecoverable,omitempty"`
+}
+
+// Simulated loss of all nodes of a failure domain
+type DomainFailure struct {
+	// Value of the domain label, or the node name if the node is a domain of its own
+	Domain string   `json:"domain"`
+	Nodes  []string `json:"nodes"`
+	// Set for a node without the domain label, which is a domain of its own
+	Unlabeled bool `json:"unlabeled,omitempty"`
+	// Results of pods of the lost nodes scheduled again on the remaining nodes
+	Results []*SchedulingResult `json:"results"`
+	// Workloads that lost pods, replicas are the lost pods, including pods preempted to schedule them
+	Workloads []WorkloadResult `json:"workloads,omitempty"`
+	// Workloads that do not get all their lost pods back, in "Kind namespace/name" format
+	Unrecovered []string `json:"unrecovered,omitempty"`
+	// Set if the failure was not simulated, e.g. because the analysis timed out
+	Message string `json:"message,omitempty"`
+}
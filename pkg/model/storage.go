@@ -0,0 +1,80 @@
+package model
+
+import (
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+// PVs and PVCs keep their storage class in the beta annotation, which is still honoured by newer clusters
+const StorageClassAnnotationKey = "volume.beta.kubernetes.io/storage-class"
+
+// Annotations marking the default StorageClass, given to PVCs that do not choose a class
+const (
+	DefaultStorageClassAnnotationKey     = "storageclass.kubernetes.io/is-default-class"
+	BetaDefaultStorageClassAnnotationKey = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// Provisioner of classes that do not provision volumes dynamically
+const NoProvisioner = "kubernetes.io/no-provisioner"
+
+// Results of binding PVCs of the request
+const (
+	// PVC is bound to an existing PV
+	ClaimBoundResult = "Bound"
+	// A new PV is provisioned for the PVC by its storage class
+	ClaimProvisionedResult = "Provisioned"
+	// No PV is available for the PVC, pods using it stay Pending
+	ClaimPendingResult = "Pending"
+)
+
+// StorageClass with only the fields needed to provision volumes
+type StorageClass struct {
+	v1.ObjectMeta     `json:"metadata,omitempty"`
+	Provisioner       string            `json:"provisioner"`
+	Parameters        map[string]string `json:"parameters,omitempty"`
+	VolumeBindingMode string            `json:"volumeBindingMode,omitempty"`
+}
+
+func (class *StorageClass) IsDefault() bool {
+	return class.Annotations[DefaultStorageClassAnnotationKey] == "true" ||
+		class.Annotations[BetaDefaultStorageClassAnnotationKey] == "true"
+}
+
+// Fields of PV and PVC specs of newer API versions, which are dropped when they are decoded
+type DroppedVolumeSpecFields struct {
+	StorageClassName *string `json:"storageClassName"`
+}
+
+// Keeps the storage class in the annotation of the object, an annotation that is already set is not overwritten.
+// Annotations map is copied, because objects created from the same template share it.
+func (fields *DroppedVolumeSpecFields) Apply(meta *v1.ObjectMeta) {
+	if fields.StorageClassName == nil {
+		return
+	}
+	if _, ok := meta.Annotations[StorageClassAnnotationKey]; ok {
+		return
+	}
+
+	annotations := map[string]string{StorageClassAnnotationKey: *fields.StorageClassName}
+	for key, value := range meta.Annotations {
+		annotations[key] = value
+	}
+	meta.Annotations = annotations
+}
+
+// Returns the storage class of a PV or a PVC and whether the class is set at all. PVCs without a class get
+// the default class when they are created, a class set to "" means no class.
+func StorageClassName(meta *v1.ObjectMeta) (string, bool) {
+	className, ok := meta.Annotations[StorageClassAnnotationKey]
+	return className, ok
+}
+
+// Result of binding a PVC of the request
+type ClaimResult struct {
+	// PVC identity in namespace/name format
+	ClaimName string `json:"claimName"`
+	Result    string `json:"result"`
+	// PV the claim is bound to, set for bound and provisioned claims
+	VolumeName   string `json:"volumeName,omitempty"`
+	StorageClass string `json:"storageClass,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
@@ -0,0 +1,20 @@
+package testutil
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes the content to a new temporary file and returns its name, the caller removes the file.
+func WriteTempFile(t *testing.T, prefix, content string) string {
+	file, err := ioutil.TempFile("", prefix)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	assert.NoError(t, err)
+
+	return file.Name()
+}
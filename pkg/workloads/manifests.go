@@ -0,0 +1,187 @@
+package workloads
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/util/yaml"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Objects decoded from manifests. Objects of unsupported kinds are skipped, see UnsupportedKindError.
+type Manifests struct {
+	Workloads []*Workload
+	// PVCs given directly, PVCs of StatefulSets are kept in their workloads
+	PersistentVolumeClaims []*v1.PersistentVolumeClaim
+	// Skipped objects in "Kind namespace/name" format
+	Ignored []string
+}
+
+// Lists errors of all objects that could not be decoded
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "; ")
+}
+
+// Decodes JSON objects, Lists are expanded into their items
+func FromJSONObjects(objects []json.RawMessage) (*Manifests, error) {
+	var manifests Manifests
+	var parseErrors []string
+
+	for i, object := range objects {
+		if err := manifests.add(object); err != nil {
+			parseErrors = append(parseErrors, fmt.Sprintf("object %d: %s", i+1, err))
+		}
+	}
+
+	if len(parseErrors) > 0 {
+		return nil, &ParseError{Errors: parseErrors}
+	}
+
+	return &manifests, nil
+}
+
+// Decodes a stream of YAML (or JSON) documents separated by "---" lines, Lists and arrays of objects
+// are expanded into their items.
+func FromYAML(reader io.Reader) (*Manifests, error) {
+	var manifests Manifests
+	var parseErrors []string
+
+	yamlReader := yaml.NewYAMLReader(bufio.NewReader(reader))
+	for document := 1; ; document++ {
+		data, err := yamlReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Sprintf("document %d: error reading document: %s", document, err))
+			break
+		}
+
+		if isEmptyDocument(data) {
+			continue
+		}
+
+		object, err := yaml.ToJSON(data)
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Sprintf("document %d: error converting YAML to JSON: %s", document, err))
+			continue
+		}
+
+		if err := manifests.add(object); err != nil {
+			parseErrors = append(parseErrors, fmt.Sprintf("document %d: %s", document, err))
+		}
+	}
+
+	if len(parseErrors) > 0 {
+		return nil, &ParseError{Errors: parseErrors}
+	}
+
+	return &manifests, nil
+}
+
+func (m *Manifests) add(object []byte) error {
+	if trimmed := bytes.TrimSpace(object); len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return fmt.Errorf("error reading array of objects: %s", err)
+		}
+		return m.addItems(items)
+	}
+
+	var list struct {
+		unversioned.TypeMeta `json:",inline"`
+		Items                []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(object, &list); err != nil {
+		return fmt.Errorf("error reading kind of object: %s", err)
+	}
+
+	if list.Kind == "List" {
+		return m.addItems(list.Items)
+	}
+
+	if list.Kind == "PersistentVolumeClaim" {
+		claim, err := claimFromJSON(object)
+		if err != nil {
+			return err
+		}
+		m.PersistentVolumeClaims = append(m.PersistentVolumeClaims, claim)
+		return nil
+	}
+
+	workload, err := FromJSON(object)
+	if unsupported, ok := err.(*UnsupportedKindError); ok {
+		m.Ignored = append(m.Ignored, fmt.Sprintf("%s %s", unsupported.Kind, unsupported.Name))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	m.Workloads = append(m.Workloads, workload)
+
+	return nil
+}
+
+func (m *Manifests) addItems(items []json.RawMessage) error {
+	for i, item := range items {
+		if err := m.add(item); err != nil {
+			return fmt.Errorf("item %d: %s", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns PVCs given directly and PVCs of all workloads
+func (m *Manifests) Claims() []*v1.PersistentVolumeClaim {
+	claims := append([]*v1.PersistentVolumeClaim(nil), m.PersistentVolumeClaims...)
+	for _, workload := range m.Workloads {
+		claims = append(claims, workload.Claims...)
+	}
+
+	return claims
+}
+
+func claimFromJSON(data []byte) (*v1.PersistentVolumeClaim, error) {
+	var claim v1.PersistentVolumeClaim
+	var object struct {
+		Spec model.DroppedVolumeSpecFields `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &claim); err != nil {
+		return nil, fmt.Errorf("error unmarshalling persistent volume claim: %s", err)
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("error unmarshalling persistent volume claim: %s", err)
+	}
+	object.Spec.Apply(&claim.ObjectMeta)
+
+	if claim.Namespace == "" {
+		claim.Namespace = v1.NamespaceDefault
+	}
+
+	return &claim, nil
+}
+
+// Documents containing only comments or whitespace are skipped
+func isEmptyDocument(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,103 @@
+package workloads
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+const multiDocumentManifest = `
+# web application
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx
+---
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pod
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+    namespace: team
+`
+
+func TestYAMLDocumentsDecoded(t *testing.T) {
+	manifests, err := FromYAML(strings.NewReader(multiDocumentManifest))
+
+	assert.NoError(t, err)
+	if assert.Len(t, manifests.Workloads, 2) {
+		assert.Equal(t, "Deployment", manifests.Workloads[0].Kind)
+		assert.Len(t, manifests.Workloads[0].Pods, 2)
+		assert.Equal(t, "Pod", manifests.Workloads[1].Kind)
+	}
+	assert.Equal(t, []string{"Service default/web", "ConfigMap team/config"}, manifests.Ignored)
+}
+
+func TestJSONArrayExpanded(t *testing.T) {
+	pods, err := os.Open("../../pod-examples/pods.json")
+	assert.NoError(t, err)
+	defer pods.Close()
+
+	manifests, err := FromYAML(pods)
+
+	assert.NoError(t, err)
+	if assert.Len(t, manifests.Workloads, 2) {
+		assert.Equal(t, "regular-pod", manifests.Workloads[0].Name)
+		assert.Equal(t, "another-regular-pod", manifests.Workloads[1].Name)
+	}
+}
+
+func TestClaimsCollectedFromManifests(t *testing.T) {
+	manifest := "kind: PersistentVolumeClaim\nmetadata:\n  name: data\nspec:\n  storageClassName: \"\"\n" +
+		"---\nkind: Pod\nmetadata:\n  name: web\n"
+
+	manifests, err := FromYAML(strings.NewReader(manifest))
+
+	assert.NoError(t, err)
+	assert.Len(t, manifests.Workloads, 1)
+	assert.Empty(t, manifests.Ignored)
+	if claims := manifests.Claims(); assert.Len(t, claims, 1) {
+		assert.Equal(t, "data", claims[0].Name)
+		assert.Equal(t, "default", claims[0].Namespace)
+		className, ok := model.StorageClassName(&claims[0].ObjectMeta)
+		assert.True(t, ok)
+		assert.Equal(t, "", className)
+	}
+}
+
+func TestYAMLParseErrorsReportedPerDocument(t *testing.T) {
+	manifest := "kind: Pod\nmetadata:\n  name: pod\n---\nkind: [\n---\nkind: Pod\nmetadata: 5\n"
+
+	_, err := FromYAML(strings.NewReader(manifest))
+
+	if assert.IsType(t, &ParseError{}, err) {
+		parseErrors := err.(*ParseError).Errors
+		if assert.Len(t, parseErrors, 2) {
+			assert.Contains(t, parseErrors[0], "document 2")
+			assert.Contains(t, parseErrors[1], "document 3")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package workloads
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Controllers that do not set owner references of their objects record the creator in this annotation
+const createdByAnnotationKey = "kubernetes.io/created-by"
+
+// Returns the kind and the identity (in namespace/name format) of the workload a pod running in the cluster
+// belongs to: the Deployment of its ReplicaSet if the ReplicaSet is one of the given ones, its controller,
+// or the pod itself if it has no controller.
+func Owner(pod *v1.Pod, replicaSets []v1beta1.ReplicaSet) (string, string) {
+	kind, name := controllerOf(&pod.ObjectMeta)
+	if kind == "" {
+		return "Pod", model.PodKey(pod.Namespace, pod.Name)
+	}
+
+	if kind == "ReplicaSet" {
+		for i := range replicaSets {
+			replicaSet := &replicaSets[i]
+			if replicaSet.Namespace != pod.Namespace || replicaSet.Name != name {
+				continue
+			}
+			if deployment := deploymentOf(replicaSet); deployment != "" {
+				return "Deployment", model.PodKey(pod.Namespace, deployment)
+			}
+		}
+	}
+
+	return kind, model.PodKey(pod.Namespace, name)
+}
+
+func controllerOf(meta *v1.ObjectMeta) (string, string) {
+	for _, owner := range meta.OwnerReferences {
+		if owner.Controller != nil && *owner.Controller {
+			return owner.Kind, owner.Name
+		}
+	}
+	if len(meta.OwnerReferences) > 0 {
+		return meta.OwnerReferences[0].Kind, meta.OwnerReferences[0].Name
+	}
+
+	var createdBy v1.SerializedReference
+	if data := meta.Annotations[createdByAnnotationKey]; data != "" && json.Unmarshal([]byte(data), &createdBy) == nil {
+		return createdBy.Reference.Kind, createdBy.Reference.Name
+	}
+
+	return "", ""
+}
+
+// ReplicaSets of Deployments are named after the Deployment and the hash of the pod template,
+// older clusters do not set their owner references.
+func deploymentOf(replicaSet *v1beta1.ReplicaSet) string {
+	if kind, name := controllerOf(&replicaSet.ObjectMeta); kind == "Deployment" {
+		return name
+	}
+
+	hash := replicaSet.Labels[v1beta1.DefaultDeploymentUniqueLabelKey]
+	if hash != "" && strings.HasSuffix(replicaSet.Name, "-"+hash) {
+		return strings.TrimSuffix(replicaSet.Name, "-"+hash)
+	}
+
+	return ""
+}
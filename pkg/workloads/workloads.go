@@ -0,0 +1,311 @@
+package workloads
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	batchv1 "k8s.io/client-go/1.5/pkg/apis/batch/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	utilrand "k8s.io/kubernetes/pkg/util/rand"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+// Length of the random suffix of names of pods created by workload controllers
+const podNameSuffixLength = 5
+
+// Maximum number of pods a single workload is expanded into
+const MaxReplicas = 5000
+
+// Pod or a workload object expanded into the pods its controller would create
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Pods      []*v1.Pod
+	// PVCs created for the pods, from volume claim templates of StatefulSets
+	Claims []*v1.PersistentVolumeClaim
+}
+
+// StatefulSet with only the fields needed to create its pods, see package model
+type statefulSet struct {
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Spec          statefulSetSpec `json:"spec,omitempty"`
+}
+
+type statefulSetSpec struct {
+	Replicas             *int32                     `json:"replicas,omitempty"`
+	Template             v1.PodTemplateSpec         `json:"template"`
+	VolumeClaimTemplates []v1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
+	ServiceName          string                     `json:"serviceName"`
+}
+
+// Returned for objects that do not create pods, like Services or ConfigMaps
+type UnsupportedKindError struct {
+	Kind string
+	// Object identity in namespace/name format
+	Name string
+}
+
+func (e *UnsupportedKindError) Error() string {
+	return fmt.Sprintf("unsupported kind %s", e.Kind)
+}
+
+// Decodes a JSON object of one of supported kinds: Pod (the default if kind is not set), Deployment, ReplicaSet,
+// StatefulSet (or PetSet) and Job, and creates pods (and PVCs of StatefulSets) the same way as its controller would.
+// UnsupportedKindError is returned for objects of other kinds.
+func FromJSON(data []byte) (*Workload, error) {
+	var object struct {
+		unversioned.TypeMeta `json:",inline"`
+		Metadata             v1.ObjectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("error reading kind of object: %s", err)
+	}
+
+	var workload *Workload
+	var err error
+	switch object.Kind {
+	case "", "Pod":
+		var pod v1.Pod
+		if err := json.Unmarshal(data, &pod); err != nil {
+			return nil, fmt.Errorf("error unmarshalling pod: %s", err)
+		}
+		workload = &Workload{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Pods: []*v1.Pod{&pod}}
+	case "Deployment":
+		var deployment v1beta1.Deployment
+		if err := json.Unmarshal(data, &deployment); err != nil {
+			return nil, fmt.Errorf("error unmarshalling deployment: %s", err)
+		}
+		workload, err = fromTemplate(object.Kind, deployment.ObjectMeta, &deployment.Spec.Template,
+			replicas(deployment.Spec.Replicas), randomPodName)
+	case "ReplicaSet":
+		var replicaSet v1beta1.ReplicaSet
+		if err := json.Unmarshal(data, &replicaSet); err != nil {
+			return nil, fmt.Errorf("error unmarshalling replica set: %s", err)
+		}
+		workload, err = fromTemplate(object.Kind, replicaSet.ObjectMeta, &replicaSet.Spec.Template,
+			replicas(replicaSet.Spec.Replicas), randomPodName)
+	case "StatefulSet", "PetSet":
+		var set statefulSet
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("error unmarshalling stateful set: %s", err)
+		}
+		workload, err = fromTemplate(object.Kind, set.ObjectMeta, &set.Spec.Template,
+			replicas(set.Spec.Replicas), orderedPodName)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range workload.Pods {
+			pod.Spec.Hostname = pod.Name
+			pod.Spec.Subdomain = set.Spec.ServiceName
+		}
+		if err := setClaimTemplatesStorageClass(&set, data); err != nil {
+			return nil, err
+		}
+		workload.Claims = statefulSetClaims(&set, workload.Pods)
+	case "Job":
+		var job batchv1.Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("error unmarshalling job: %s", err)
+		}
+		workload, err = fromTemplate(object.Kind, job.ObjectMeta, &job.Spec.Template, jobParallelism(&job.Spec),
+			randomPodName)
+	default:
+		namespace := object.Metadata.Namespace
+		if namespace == "" {
+			namespace = v1.NamespaceDefault
+		}
+
+		return nil, &UnsupportedKindError{Kind: object.Kind, Name: model.PodKey(namespace, object.Metadata.Name)}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setDroppedSpecFields(workload, data); err != nil {
+		return nil, err
+	}
+
+	return workload, nil
+}
+
+// Summarizes results of pods of every workload, pods given directly are skipped
+func Summarize(workloads []*Workload, results []*model.SchedulingResult) []model.WorkloadResult {
+	resultsByPod := make(map[string]*model.SchedulingResult, len(results))
+	for _, result := range results {
+		resultsByPod[result.PodName] = result
+	}
+
+	var summary []model.WorkloadResult
+	for _, workload := range workloads {
+		if workload.Kind == "Pod" {
+			continue
+		}
+
+		scheduled := 0
+		for _, pod := range workload.Pods {
+			result, ok := resultsByPod[model.PodKey(pod.Namespace, pod.Name)]
+			if ok && result.Result == "Scheduled" {
+				scheduled++
+			}
+		}
+
+		summary = append(summary, model.WorkloadResult{
+			Kind:      workload.Kind,
+			Name:      model.PodKey(workload.Namespace, workload.Name),
+			Replicas:  len(workload.Pods),
+			Scheduled: scheduled,
+			Message:   fmt.Sprintf("%d of %d replicas schedulable", scheduled, len(workload.Pods)),
+		})
+	}
+
+	return summary
+}
+
+// Newer API versions choose the scheduler and the priority with fields of the pod spec (or of the pod template),
+// which are dropped when the object is decoded. They are kept in annotations, see model.DroppedPodSpecFields.
+func setDroppedSpecFields(workload *Workload, data []byte) error {
+	var object struct {
+		Spec struct {
+			model.DroppedPodSpecFields `json:",inline"`
+			Template                   struct {
+				Spec model.DroppedPodSpecFields `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("error reading pod spec: %s", err)
+	}
+
+	fields := &object.Spec.DroppedPodSpecFields
+	if workload.Kind != "Pod" {
+		fields = &object.Spec.Template.Spec
+	}
+
+	for _, pod := range workload.Pods {
+		fields.Apply(pod)
+	}
+
+	return nil
+}
+
+// Storage classes of claim templates given in spec.storageClassName are kept in annotations,
+// see model.DroppedVolumeSpecFields
+func setClaimTemplatesStorageClass(set *statefulSet, data []byte) error {
+	var object struct {
+		Spec struct {
+			VolumeClaimTemplates []struct {
+				Spec model.DroppedVolumeSpecFields `json:"spec"`
+			} `json:"volumeClaimTemplates"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("error reading volume claim templates: %s", err)
+	}
+
+	for i, template := range object.Spec.VolumeClaimTemplates {
+		template.Spec.Apply(&set.Spec.VolumeClaimTemplates[i].ObjectMeta)
+	}
+
+	return nil
+}
+
+// StatefulSet controller creates a PVC named <template>-<pod> from every claim template for every pod,
+// and mounts it in the pod as the volume named like the template.
+func statefulSetClaims(set *statefulSet, pods []*v1.Pod) []*v1.PersistentVolumeClaim {
+	var claims []*v1.PersistentVolumeClaim
+
+	for _, pod := range pods {
+		volumes := make([]v1.Volume, 0, len(pod.Spec.Volumes)+len(set.Spec.VolumeClaimTemplates))
+		templateVolumes := make(map[string]bool)
+
+		for _, template := range set.Spec.VolumeClaimTemplates {
+			claim := &v1.PersistentVolumeClaim{
+				ObjectMeta: template.ObjectMeta,
+				Spec:       template.Spec,
+			}
+			claim.Namespace = pod.Namespace
+			claim.Name = fmt.Sprintf("%s-%s", template.Name, pod.Name)
+			claims = append(claims, claim)
+
+			volumes = append(volumes, v1.Volume{
+				Name: template.Name,
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim.Name},
+				},
+			})
+			templateVolumes[template.Name] = true
+		}
+
+		// Volumes of the pod template are shared by all pods, so every pod gets its own copy
+		for _, volume := range pod.Spec.Volumes {
+			if !templateVolumes[volume.Name] {
+				volumes = append(volumes, volume)
+			}
+		}
+		pod.Spec.Volumes = volumes
+	}
+
+	return claims
+}
+
+func fromTemplate(kind string, meta v1.ObjectMeta, template *v1.PodTemplateSpec, replicas int,
+	podName func(workloadName string, index int) string) (*Workload, error) {
+	namespace := meta.Namespace
+	if namespace == "" {
+		namespace = v1.NamespaceDefault
+	}
+
+	if replicas < 0 {
+		return nil, fmt.Errorf("invalid number of replicas of %s %s: %d is negative", kind, meta.Name, replicas)
+	}
+	if replicas > MaxReplicas {
+		return nil, fmt.Errorf("invalid number of replicas of %s %s: %d is more than %d", kind, meta.Name,
+			replicas, MaxReplicas)
+	}
+
+	pods := make([]*v1.Pod, replicas)
+	for i := range pods {
+		pod := &v1.Pod{
+			ObjectMeta: template.ObjectMeta,
+			Spec:       template.Spec,
+		}
+		pod.Namespace = namespace
+		pod.Name = podName(meta.Name, i)
+		pods[i] = pod
+	}
+
+	return &Workload{Kind: kind, Namespace: namespace, Name: meta.Name, Pods: pods}, nil
+}
+
+func randomPodName(workloadName string, _ int) string {
+	return fmt.Sprintf("%s-%s", workloadName, utilrand.String(podNameSuffixLength))
+}
+
+func orderedPodName(workloadName string, index int) string {
+	return fmt.Sprintf("%s-%d", workloadName, index)
+}
+
+// Controllers create one replica if the number of replicas is not set
+func replicas(replicas *int32) int {
+	if replicas == nil {
+		return 1
+	}
+
+	return int(*replicas)
+}
+
+// Job controller runs parallelism pods at once, but not more than the number of completions.
+// Negative completions are returned as they are, so that they are rejected like negative parallelism.
+func jobParallelism(spec *batchv1.JobSpec) int {
+	parallelism := replicas(spec.Parallelism)
+	if spec.Completions != nil && int(*spec.Completions) < parallelism {
+		return int(*spec.Completions)
+	}
+
+	return parallelism
+}
@@ -0,0 +1,213 @@
+package workloads
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+
+	"github.com/Prytu/risk-advisor/pkg/model"
+)
+
+func TestPodWithoutKind(t *testing.T) {
+	workload, err := FromJSON([]byte(`{"metadata": {"name": "pod"}}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Pod", workload.Kind)
+	if assert.Len(t, workload.Pods, 1) {
+		assert.Equal(t, "pod", workload.Pods[0].Name)
+	}
+}
+
+func TestDeploymentExpandedIntoReplicas(t *testing.T) {
+	deployment := `{
+		"kind": "Deployment",
+		"metadata": {"name": "web", "namespace": "team"},
+		"spec": {
+			"replicas": 3,
+			"template": {
+				"metadata": {"labels": {"app": "web"}},
+				"spec": {"containers": [{"name": "web", "image": "nginx"}]}
+			}
+		}
+	}`
+
+	workload, err := FromJSON([]byte(deployment))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Deployment", workload.Kind)
+	assert.Len(t, workload.Pods, 3)
+	names := make(map[string]bool)
+	for _, pod := range workload.Pods {
+		assert.Equal(t, "team", pod.Namespace)
+		assert.Regexp(t, "^web-[a-z0-9]{5}$", pod.Name)
+		assert.Equal(t, "web", pod.Labels["app"])
+		assert.Equal(t, "nginx", pod.Spec.Containers[0].Image)
+		names[pod.Name] = true
+	}
+	assert.Len(t, names, 3)
+}
+
+func TestSchedulerNameKeptAsAnnotation(t *testing.T) {
+	pod, err := FromJSON([]byte(`{"metadata": {"name": "pod"}, "spec": {"schedulerName": "gpu-scheduler"}}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "gpu-scheduler", model.PodSchedulerName(pod.Pods[0]))
+
+	deployment, err := FromJSON([]byte(`{
+		"kind": "Deployment",
+		"metadata": {"name": "web"},
+		"spec": {"replicas": 2, "template": {"spec": {"schedulerName": "gpu-scheduler"}}}
+	}`))
+
+	assert.NoError(t, err)
+	for _, pod := range deployment.Pods {
+		assert.Equal(t, "gpu-scheduler", model.PodSchedulerName(pod))
+	}
+
+	pod, err = FromJSON([]byte(`{"metadata": {"name": "pod"}}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "default-scheduler", model.PodSchedulerName(pod.Pods[0]))
+}
+
+func TestPriorityKeptAsAnnotations(t *testing.T) {
+	deployment, err := FromJSON([]byte(`{
+		"kind": "Deployment",
+		"metadata": {"name": "web"},
+		"spec": {"replicas": 2, "template": {"spec": {"priorityClassName": "high", "priority": 1000}}}
+	}`))
+
+	assert.NoError(t, err)
+	for _, pod := range deployment.Pods {
+		assert.Equal(t, "high", pod.Annotations[model.PriorityClassNameAnnotationKey])
+		if assert.NotNil(t, model.PodPriority(pod)) {
+			assert.Equal(t, int32(1000), *model.PodPriority(pod))
+		}
+	}
+}
+
+func TestStatefulSetPodsHaveOrdinalNames(t *testing.T) {
+	set := `{
+		"kind": "StatefulSet",
+		"metadata": {"name": "db"},
+		"spec": {"replicas": 2, "serviceName": "db", "template": {"spec": {"containers": [{"name": "db"}]}}}
+	}`
+
+	workload, err := FromJSON([]byte(set))
+
+	assert.NoError(t, err)
+	if assert.Len(t, workload.Pods, 2) {
+		assert.Equal(t, "db-0", workload.Pods[0].Name)
+		assert.Equal(t, "db-1", workload.Pods[1].Name)
+		assert.Equal(t, "default", workload.Pods[1].Namespace)
+		assert.Equal(t, "db", workload.Pods[1].Spec.Subdomain)
+	}
+}
+
+func TestStatefulSetClaimTemplatesCreateClaimPerPod(t *testing.T) {
+	set := `{
+		"kind": "StatefulSet",
+		"metadata": {"name": "db"},
+		"spec": {
+			"replicas": 2,
+			"serviceName": "db",
+			"template": {"spec": {"containers": [{"name": "db"}], "volumes": [{"name": "data", "emptyDir": {}}]}},
+			"volumeClaimTemplates": [{"metadata": {"name": "data"}, "spec": {"storageClassName": "fast"}}]
+		}
+	}`
+
+	workload, err := FromJSON([]byte(set))
+
+	assert.NoError(t, err)
+	if assert.Len(t, workload.Claims, 2) && assert.Len(t, workload.Pods, 2) {
+		assert.Equal(t, "data-db-1", workload.Claims[1].Name)
+		assert.Equal(t, "default", workload.Claims[1].Namespace)
+		className, _ := model.StorageClassName(&workload.Claims[1].ObjectMeta)
+		assert.Equal(t, "fast", className)
+
+		if assert.Len(t, workload.Pods[1].Spec.Volumes, 1) {
+			volume := workload.Pods[1].Spec.Volumes[0]
+			assert.Equal(t, "data", volume.Name)
+			assert.Equal(t, "data-db-1", volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+}
+
+func TestJobRunsParallelismPods(t *testing.T) {
+	job := `{
+		"kind": "Job",
+		"metadata": {"name": "batch"},
+		"spec": {"parallelism": 4, "completions": 2, "template": {"spec": {"containers": [{"name": "batch"}]}}}
+	}`
+
+	workload, err := FromJSON([]byte(job))
+
+	assert.NoError(t, err)
+	assert.Len(t, workload.Pods, 2)
+}
+
+func TestInvalidNumberOfReplicasRejected(t *testing.T) {
+	for _, object := range []string{
+		`{"kind": "Deployment", "metadata": {"name": "web"}, "spec": {"replicas": -1}}`,
+		`{"kind": "Job", "metadata": {"name": "batch"}, "spec": {"parallelism": 2, "completions": -1}}`,
+		`{"kind": "ReplicaSet", "metadata": {"name": "web"}, "spec": {"replicas": 1000000}}`,
+	} {
+		_, err := FromJSON([]byte(object))
+
+		assert.Error(t, err, object)
+	}
+}
+
+func TestUnsupportedKind(t *testing.T) {
+	_, err := FromJSON([]byte(`{"kind": "Service", "metadata": {"name": "web"}}`))
+
+	assert.Error(t, err)
+}
+
+func TestSummarizeCountsScheduledReplicas(t *testing.T) {
+	workload, err := FromJSON([]byte(`{"kind": "ReplicaSet", "metadata": {"name": "web"}, "spec": {"replicas": 2}}`))
+	assert.NoError(t, err)
+	pod, err := FromJSON([]byte(`{"metadata": {"name": "pod"}}`))
+	assert.NoError(t, err)
+
+	results := []*model.SchedulingResult{
+		{PodName: model.PodKey("default", workload.Pods[0].Name), Result: "Scheduled"},
+		{PodName: model.PodKey("default", workload.Pods[1].Name), Result: "FailedScheduling"},
+		{PodName: "default/pod", Result: "Scheduled"},
+	}
+
+	summary := Summarize([]*Workload{workload, pod}, results)
+
+	assert.Equal(t, []model.WorkloadResult{{
+		Kind:      "ReplicaSet",
+		Name:      "default/web",
+		Replicas:  2,
+		Scheduled: 1,
+		Message:   "1 of 2 replicas schedulable",
+	}}, summary)
+}
+
+func TestOwnerOfReplicaSetPodIsDeployment(t *testing.T) {
+	replicaSets := []v1beta1.ReplicaSet{{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "default",
+			Name:      "web-1234",
+			Labels:    map[string]string{v1beta1.DefaultDeploymentUniqueLabelKey: "1234"},
+		},
+	}}
+	pod := &v1.Pod{ObjectMeta: v1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "web-1234-abcde",
+		Annotations: map[string]string{createdByAnnotationKey: `{"kind":"SerializedReference","reference":{"kind":"ReplicaSet","name":"web-1234"}}`},
+	}}
+
+	kind, name := Owner(pod, replicaSets)
+	assert.Equal(t, "Deployment", kind)
+	assert.Equal(t, "default/web", name)
+
+	kind, name = Owner(&v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "pod"}}, replicaSets)
+	assert.Equal(t, "Pod", kind)
+	assert.Equal(t, "default/pod", name)
+}